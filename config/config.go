@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"testing"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,15 +18,63 @@ type Config struct {
 		Vlan         [2]int `yaml:"vlan"`         // wireguard虚拟局域网
 		Secret       string `yaml:"secret"`       // 加密密钥
 		Debug        bool   `yaml:"debug"`        //
+		ApiDoc       bool   `yaml:"apiDoc"`       // 是否提供OpenAPI文档和在线调试页面
 		TokenEncrypt bool   `yaml:"tokenEncrypt"` // token是加密或签名
 		TokenSize    int    `yaml:"tokenSize"`    // token最大长度
 		TokenExpire  int    `yaml:"tokenExpire"`  // token过期时间
-		Websocket    struct {
-			WsLifeTime    uint32 `yaml:"wsLifeTime"`    // ws连接生命周期
-			WsTaskTimeout uint32 `yaml:"wsTaskTimeout"` // ws处理超时
-			WsHeartbeat   uint32 `yaml:"wsHeartbeat"`   // ws心跳检测
-			WsMaxWaiting  uint8  `yaml:"wsMaxWaiting"`  // ws单个连接最大等待处理数量
+		ApiDocViewer struct {
+			Assets       string `yaml:"assets"`       // swagger-ui资源地址，需固定到具体版本
+			CssIntegrity string `yaml:"cssIntegrity"` // swagger-ui.css的SRI哈希
+			JsIntegrity  string `yaml:"jsIntegrity"`  // swagger-ui-bundle.js的SRI哈希
+		} `yaml:"apiDocViewer"` // 在线调试页面，未配置哈希时不提供
+		Websocket struct {
+			WsLifeTime      uint32 `yaml:"wsLifeTime"`      // ws连接生命周期
+			WsTaskTimeout   uint32 `yaml:"wsTaskTimeout"`   // ws处理超时
+			WsHeartbeat     uint32 `yaml:"wsHeartbeat"`     // ws心跳检测
+			WsMaxWaiting    uint8  `yaml:"wsMaxWaiting"`    // ws单个连接最大等待处理数量
+			Workers         int    `yaml:"workers"`         // 全局请求处理协程数，0为cpu核数*8
+			ConnConcurrency int    `yaml:"connConcurrency"` // 单个连接同时处理的请求数
+			ResumeGrace     uint32 `yaml:"resumeGrace"`     // 网络异常断开后会话保留时间，0为不保留
+			ResumeBuffer    int    `yaml:"resumeBuffer"`    // 会话保留期间最多缓存的消息数
+			AckRetry        uint32 `yaml:"ackRetry"`        // 未确认的重要推送重试间隔，0为不重试
+			AckMaxRetry     int    `yaml:"ackMaxRetry"`     // 重要推送最大重试次数，超出后断开连接
+			AckMaxPending   int    `yaml:"ackMaxPending"`   // 单个连接最多未确认的重要推送数，超出后断开连接
+			SendQueue       int    `yaml:"sendQueue"`       // 单个连接发送队列长度
+			Overflow        string `yaml:"overflow"`        // 发送队列溢出策略 dropOldest dropNew disconnect
+			WriteTimeout    uint32 `yaml:"writeTimeout"`    // 单条消息写入超时时间
+			ReadLimit       int64  `yaml:"readLimit"`       // 接收单条消息最大字节数，0为不限制
+			Compression     struct {
+				Enable    bool `yaml:"enable"`    // 是否协商permessage-deflate压缩
+				Level     int  `yaml:"level"`     // 压缩等级 1-9，-2为仅哈夫曼编码
+				Threshold int  `yaml:"threshold"` // 超过该字节数的消息才压缩
+			} `yaml:"compression"`
+			Signature struct {
+				Window uint32   `yaml:"window"` // 签名时间戳允许的偏差
+				Groups []string `yaml:"groups"` // 需要签名的ws处理组
+			} `yaml:"signature"` // ws请求签名
+			Session struct {
+				SessionPolicy `yaml:",inline"`
+				Permissions   map[string]SessionPolicy `yaml:"permissions"` // 按权限覆盖的会话策略
+			} `yaml:"session"` // 同一用户多端连接策略
 		} `yaml:"websocket"`
+		Room struct {
+			Persist        bool   `yaml:"persist"`        // 房间是否持久化
+			RecoverGrace   uint32 `yaml:"recoverGrace"`   // 重启后成员重连保留vlan的时间
+			HistorySize    int    `yaml:"historySize"`    // 每个房间保留的消息记录数量
+			HistoryPersist bool   `yaml:"historyPersist"` // 消息记录是否保存至redis
+		} `yaml:"room"`
+		Moderation struct {
+			MaxLength   int      `yaml:"maxLength"`   // 消息最大长度
+			Mode        string   `yaml:"mode"`        // 敏感词处理方式 mask: 替换为* reject: 拒绝发送
+			Words       []string `yaml:"words"`       // 敏感词
+			Patterns    []string `yaml:"patterns"`    // 敏感词正则
+			FloodCount  int      `yaml:"floodCount"`  // 单个用户在同一房间/频道周期内最大消息数
+			FloodPeriod uint32   `yaml:"floodPeriod"` // 刷屏检测周期
+			RecentSize  int      `yaml:"recentSize"`  // 可被举报的最近频道消息数量
+		} `yaml:"moderation"`
+		Channel struct {
+			CreatePermission string `yaml:"createPermission"` // 除管理员外允许创建频道的权限，为空时仅管理员可创建
+		} `yaml:"channel"`
 		Logger struct {
 			Path  string `yaml:"path"`  // 日志文件位置
 			Level string `yaml:"level"` // 日志等级
@@ -51,10 +100,22 @@ type Config struct {
 	}
 }
 
+// SessionPolicy 多端会话策略
+type SessionPolicy struct {
+	Mode        string `yaml:"mode"`        // single: 单会话 multi: 多会话 device: 按设备mac区分
+	MaxSessions int    `yaml:"maxSessions"` // multi和device模式下的最大会话数，0为不限制
+}
+
 var Conf *Config
 
 func init() {
 	data, err := os.ReadFile("./config.yaml")
+	// 单元测试时没有配置文件，使用零值配置并输出日志到控制台
+	if err != nil && testing.Testing() {
+		Conf = &Config{}
+		Conf.Server.Debug = true
+		return
+	}
 	if err != nil {
 		log.Fatalf("load config failed: %s", err.Error())
 	}
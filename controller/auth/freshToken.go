@@ -3,6 +3,7 @@ package auth
 import (
 	"ginWeb/config"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/auth"
 	"time"
 
@@ -42,5 +43,6 @@ func (receiver FreshToken) V1(c *gin.Context) {
 }
 
 func (receiver FreshToken) RegisterRoute(r string, g *gin.RouterGroup) {
-	g.Handle("GET", r, receiver.V1)
+	openapi.Handle(g, "GET", r, receiver.V1).
+		Doc("刷新token有效期，返回新token").Returns("")
 }
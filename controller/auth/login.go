@@ -6,6 +6,7 @@ import (
 	"ginWeb/model"
 	"ginWeb/model/systemMode"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/auth"
 	"ginWeb/utils/database"
 	"net/http"
@@ -68,5 +69,6 @@ func (receiver Login) V1(c *gin.Context) {
 
 func (receiver Login) RegisterRoute(r string, g *gin.RouterGroup) {
 	// 添加独立限流器
-	g.Handle("POST", r, middleware.NewIpLimiter(5, 0, 0, g.BasePath()+r).HttpHandle, receiver.V1)
+	openapi.Handle(g, "POST", r, middleware.NewIpLimiter(5, 0, 0, g.BasePath()+r).HttpHandle, receiver.V1).
+		Doc("账号密码登录，返回token").Body(postData{}).Returns("")
 }
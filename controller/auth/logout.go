@@ -3,6 +3,7 @@ package auth
 import (
 	reCache "ginWeb/service/cache"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/auth"
 	"time"
 
@@ -35,5 +36,6 @@ func (receiver Logout) V1(c *gin.Context) {
 }
 
 func (receiver Logout) RegisterRoute(r string, g *gin.RouterGroup) {
-	g.Handle("GET", r, receiver.V1)
+	openapi.Handle(g, "GET", r, receiver.V1).
+		Doc("退出登录，当前token失效").Returns("")
 }
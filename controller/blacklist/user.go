@@ -3,6 +3,7 @@ package blacklist
 import (
 	"ginWeb/model/systemMode"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/auth"
 
 	"github.com/gin-gonic/gin"
@@ -24,8 +25,19 @@ func mapType(r string) int {
 
 type UserList struct{}
 
+// 拉黑目标参数
+type targetQuery struct {
+	Id string `form:"id" binding:"required"`
+}
+
 func (u UserList) Add(ctx *gin.Context) {
-	target := ctx.Query("id")
+	var q targetQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
 	type_ := ctx.Param("type")
 	tokenS, f := ctx.Get("token")
 	token, flag := tokenS.(*auth.Token)
@@ -38,7 +50,7 @@ func (u UserList) Add(ctx *gin.Context) {
 	}
 	data := systemMode.UserBlacklist{
 		UserUuid:   token.UserUUID,
-		TargetUuid: target,
+		TargetUuid: q.Id,
 		Type:       mapType(type_),
 	}
 	err := data.Add()
@@ -54,7 +66,13 @@ func (u UserList) Add(ctx *gin.Context) {
 }
 
 func (u UserList) Delete(ctx *gin.Context) {
-	target := ctx.Query("id")
+	var q targetQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
 	type_ := ctx.Param("type")
 	tokenS, f := ctx.Get("token")
 	token, flag := tokenS.(*auth.Token)
@@ -67,7 +85,7 @@ func (u UserList) Delete(ctx *gin.Context) {
 	}
 	data := systemMode.UserBlacklist{
 		UserUuid:   token.UserUUID,
-		TargetUuid: target,
+		TargetUuid: q.Id,
 		Type:       mapType(type_),
 	}
 	err := data.Delete()
@@ -84,6 +102,8 @@ func (u UserList) Delete(ctx *gin.Context) {
 
 func (u UserList) RegisterRoute(route string, group *gin.RouterGroup) {
 	g := group.Group(route)
-	g.Handle("GET", "add/:type", u.Add)
-	g.Handle("GET", "delete/:type", u.Delete)
+	openapi.Handle(g, "GET", "add/:type", u.Add).
+		Doc("添加黑名单，type: uuid ip device").Query(targetQuery{}).Returns("")
+	openapi.Handle(g, "GET", "delete/:type", u.Delete).
+		Doc("移除黑名单，type: uuid ip device").Query(targetQuery{}).Returns("")
 }
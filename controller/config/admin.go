@@ -4,6 +4,7 @@ import (
 	"ginWeb/config"
 	"ginWeb/middleware"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,15 +16,22 @@ func (a Admin) GetConfig(ctx *gin.Context) {
 
 	ctx.JSON(200, dataType.JsonRes{
 		Code: dataType.Success,
-		Data: map[string]any{
-			"enablePublicRegister": config.DynamicConf.EnablePublicRegister(),
+		Data: dynamicConfigs{
+			EnablePublicRegister: config.DynamicConf.EnablePublicRegister(),
 		},
 	})
 }
 
 func (a Admin) SetPublicRegister(ctx *gin.Context) {
-	v := ctx.Query("enable")
-	switch v {
+	var q publicRegisterQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code:    dataType.WrongData,
+			Message: err.Error(),
+		})
+		return
+	}
+	switch q.Enable {
 	case "true":
 		config.DynamicConf.Set(config.EnablePublicRegister, true)
 	case "false":
@@ -41,8 +49,20 @@ func (a Admin) SetPublicRegister(ctx *gin.Context) {
 	})
 }
 
+// 动态配置
+type dynamicConfigs struct {
+	EnablePublicRegister bool `json:"enablePublicRegister"`
+}
+
+// 公开注册开关参数
+type publicRegisterQuery struct {
+	Enable string `form:"enable" binding:"required,oneof=true false"`
+}
+
 func (a Admin) RegisterRoute(route string, g *gin.RouterGroup) {
-	group := g.Group(route)
-	group.Handle("GET", "/getDynamicConfigs", middleware.NewPermission([]string{"admin"}).HttpHandle, a.GetConfig)
-	group.Handle("GET", "/setPublicRegister", middleware.NewPermission([]string{"admin"}).HttpHandle, a.SetPublicRegister)
+	group := openapi.Guard(g.Group(route), middleware.NewPermission([]string{"admin"}))
+	openapi.Handle(group, "GET", "/getDynamicConfigs", a.GetConfig).
+		Doc("获取动态配置").Returns(dynamicConfigs{})
+	openapi.Handle(group, "GET", "/setPublicRegister", a.SetPublicRegister).
+		Doc("开启或关闭公开注册").Query(publicRegisterQuery{}).Returns(false)
 }
@@ -2,6 +2,7 @@ package debug
 
 import (
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/service/wireguard"
 
 	"github.com/gin-gonic/gin"
@@ -27,5 +28,6 @@ func (w WgDebug) IpcConfig(ctx *gin.Context) {
 
 func (w WgDebug) RegisterRoute(r string, g *gin.RouterGroup) {
 	group := g.Group(r)
-	group.Handle("GET", "/ipcConfig", w.IpcConfig)
+	openapi.Handle(group, "GET", "/ipcConfig", w.IpcConfig).
+		Doc("wireguard设备配置").Returns("")
 }
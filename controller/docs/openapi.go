@@ -0,0 +1,40 @@
+package docs
+
+import (
+	"ginWeb/config"
+	"ginWeb/service/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导出文档的标题
+const apiTitle = "ginWeb http api"
+
+type OpenApi struct {
+}
+
+// Spec OpenAPI文档
+func (o OpenApi) Spec(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, openapi.Document(apiTitle))
+}
+
+// 在线调试页面的静态资源
+func viewerAssets() openapi.ViewerAssets {
+	v := config.Conf.Server.ApiDocViewer
+	return openapi.ViewerAssets{Base: v.Assets, CssIntegrity: v.CssIntegrity, JsIntegrity: v.JsIntegrity}
+}
+
+// Viewer 在线调试页面
+func (o OpenApi) Viewer(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(openapi.Viewer(apiTitle, ctx.FullPath()+"/openapi.json", viewerAssets())))
+}
+
+func (o OpenApi) RegisterRoute(r string, g *gin.RouterGroup) {
+	group := g.Group(r)
+	// 未配置资源完整性校验时不加载外部脚本，仅提供文档
+	if viewerAssets().Verified() {
+		group.Handle("GET", "", o.Viewer)
+	}
+	group.Handle("GET", "openapi.json", o.Spec)
+}
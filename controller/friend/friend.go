@@ -0,0 +1,201 @@
+package friend
+
+import (
+	"errors"
+	"ginWeb/model/systemMode"
+	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
+	"ginWeb/service/wes/friend"
+	"ginWeb/service/wes/subscribe"
+	"ginWeb/utils/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Friend struct{}
+
+// 获取请求token，不存在时返回错误响应
+func getToken(ctx *gin.Context) (*auth.Token, bool) {
+	tokenS, _ := ctx.Get("token")
+	token, ok := tokenS.(*auth.Token)
+	if !ok {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.NoToken, Message: "no token",
+		})
+	}
+	return token, ok
+}
+
+// 获取目标用户uuid参数
+func getTarget(ctx *gin.Context) (string, bool) {
+	var q targetQuery
+	if !bindQuery(ctx, &q) {
+		return "", false
+	}
+	return q.Id, true
+}
+
+// 按结构体绑定并校验查询参数，失败时直接返回错误
+func bindQuery(ctx *gin.Context, q interface{}) bool {
+	if err := ctx.ShouldBindQuery(q); err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+func friendCode(err error) int {
+	switch {
+	case errors.Is(err, friend.ErrBlocked):
+		return dataType.DeniedByPermission
+	case errors.Is(err, friend.ErrUserNotFound), errors.Is(err, systemMode.ErrFriendNotFound):
+		return dataType.NotFound
+	default:
+		return dataType.WrongData
+	}
+}
+
+// List 好友列表及在线状态
+func (f Friend) List(ctx *gin.Context) {
+	token, ok := getToken(ctx)
+	if !ok {
+		return
+	}
+	friends, err := friend.Friends.List(token.UserUUID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.Unknown, Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, dataType.JsonRes{
+		Code: dataType.Success, Data: friends,
+	})
+}
+
+// Requests 收到的待处理好友申请
+func (f Friend) Requests(ctx *gin.Context) {
+	token, ok := getToken(ctx)
+	if !ok {
+		return
+	}
+	requests, err := systemMode.FriendRequestsOf(token.UserUUID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.Unknown, Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, dataType.JsonRes{
+		Code: dataType.Success, Data: requests,
+	})
+}
+
+// 目标用户参数
+type targetQuery struct {
+	Id string `form:"id" binding:"required"`
+}
+
+// 好友申请参数
+type requestQuery struct {
+	Id      string `form:"id" binding:"required"`
+	Message string `form:"message" binding:"max=255"`
+}
+
+// 好友申请结果，对方已向自己发送申请时直接成为好友
+type requestResult struct {
+	Accepted bool `json:"accepted"`
+}
+
+// Request 发送好友申请 id: 目标用户uuid message: 附言
+func (f Friend) Request(ctx *gin.Context) {
+	token, ok := getToken(ctx)
+	if !ok {
+		return
+	}
+	var q requestQuery
+	if !bindQuery(ctx, &q) {
+		return
+	}
+	accepted, err := friend.Friends.Request(token.UserUUID, token.Username, q.Id, q.Message)
+	if err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: friendCode(err), Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, dataType.JsonRes{
+		Code: dataType.Success, Data: requestResult{Accepted: accepted},
+	})
+}
+
+func (f Friend) reply(ctx *gin.Context, accept bool) {
+	token, ok := getToken(ctx)
+	if !ok {
+		return
+	}
+	target, ok := getTarget(ctx)
+	if !ok {
+		return
+	}
+	err := friend.Friends.Reply(token.UserUUID, token.Username, target, accept)
+	if err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: friendCode(err), Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, dataType.JsonRes{
+		Code: dataType.Success, Data: "success",
+	})
+}
+
+// Accept 接受好友申请 id: 申请者用户uuid
+func (f Friend) Accept(ctx *gin.Context) {
+	f.reply(ctx, true)
+}
+
+// Reject 拒绝好友申请 id: 申请者用户uuid
+func (f Friend) Reject(ctx *gin.Context) {
+	f.reply(ctx, false)
+}
+
+// Remove 删除好友 id: 好友用户uuid
+func (f Friend) Remove(ctx *gin.Context) {
+	token, ok := getToken(ctx)
+	if !ok {
+		return
+	}
+	target, ok := getTarget(ctx)
+	if !ok {
+		return
+	}
+	err := friend.Friends.Remove(token.UserUUID, token.Username, target)
+	if err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: friendCode(err), Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, dataType.JsonRes{
+		Code: dataType.Success, Data: "success",
+	})
+}
+
+func (f Friend) RegisterRoute(route string, group *gin.RouterGroup) {
+	g := group.Group(route)
+	openapi.Handle(g, "GET", "list", f.List).
+		Doc("好友列表及在线状态").Returns([]subscribe.PresenceInfo{})
+	openapi.Handle(g, "GET", "requests", f.Requests).
+		Doc("收到的待处理好友申请").Returns([]systemMode.Friendship{})
+	openapi.Handle(g, "GET", "request", f.Request).
+		Doc("发送好友申请").Query(requestQuery{}).Returns(requestResult{})
+	openapi.Handle(g, "GET", "accept", f.Accept).
+		Doc("接受好友申请").Query(targetQuery{}).Returns("")
+	openapi.Handle(g, "GET", "reject", f.Reject).
+		Doc("拒绝好友申请").Query(targetQuery{}).Returns("")
+	openapi.Handle(g, "GET", "remove", f.Remove).
+		Doc("删除好友").Query(targetQuery{}).Returns("")
+}
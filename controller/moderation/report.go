@@ -0,0 +1,92 @@
+package moderation
+
+import (
+	"ginWeb/middleware"
+	"ginWeb/model/chatMode"
+	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
+	"ginWeb/utils/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Report struct {
+}
+
+// 举报查询参数
+type listQuery struct {
+	Page   int `form:"page,default=1" binding:"min=1"`
+	Size   int `form:"size,default=20" binding:"min=1,max=100"`
+	Status int `form:"status,default=-1"` // -1为全部
+}
+
+// 举报分页结果
+type reportList struct {
+	Total   int64                    `json:"total"`
+	Reports []chatMode.MessageReport `json:"reports"`
+}
+
+// 处理举报参数
+type handleQuery struct {
+	Id     int64 `form:"id" binding:"required"`
+	Status int   `form:"status" binding:"required"`
+}
+
+// List 分页查询举报
+func (r Report) List(ctx *gin.Context) {
+	var q listQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	reports, total, err := chatMode.ListReports(q.Status, q.Page, q.Size)
+	if err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.Unknown, Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, dataType.JsonRes{
+		Code: dataType.Success,
+		Data: reportList{Total: total, Reports: reports},
+	})
+}
+
+// Handle 处理举报 status: 1举报成立 2驳回
+func (r Report) Handle(ctx *gin.Context) {
+	var q handleQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	tokenS, _ := ctx.Get("token")
+	token, ok := tokenS.(*auth.Token)
+	if !ok {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.NoToken, Message: "no token",
+		})
+		return
+	}
+	err := chatMode.HandleReport(q.Id, q.Status, token.UserUUID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, dataType.JsonRes{
+		Code: dataType.Success, Data: "success",
+	})
+}
+
+func (r Report) RegisterRoute(route string, g *gin.RouterGroup) {
+	group := openapi.Guard(g.Group(route), middleware.NewPermission([]string{"admin"}))
+	openapi.Handle(group, "GET", "list", r.List).
+		Doc("分页查询举报").Query(listQuery{}).Returns(reportList{})
+	openapi.Handle(group, "GET", "handle", r.Handle).
+		Doc("处理举报，status: 1举报成立 2驳回").Query(handleQuery{}).Returns("")
+}
@@ -5,6 +5,7 @@ import (
 	"ginWeb/model/authMode"
 	"ginWeb/model/systemMode"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/database"
 	"github.com/gin-gonic/gin"
 )
@@ -116,9 +117,9 @@ func (g Grant) GroupToUser(ctx *gin.Context) {
 }
 
 func (g Grant) RegisterRoute(r string, router *gin.RouterGroup) {
-	granter := router.Group(r)
-
-	granter.Use(middleware.NewPermission([]string{"admin"}).HttpHandle)
-	granter.Handle("POST", "/roleToUser", g.RoleToUser)
-	granter.Handle("POST", "/groupToUser", g.GroupToUser)
+	granter := openapi.Guard(router.Group(r), middleware.NewPermission([]string{"admin"}))
+	openapi.Handle(granter, "POST", "/roleToUser", g.RoleToUser).
+		Doc("赋予用户角色").Body(data{}).Returns("")
+	openapi.Handle(granter, "POST", "/groupToUser", g.GroupToUser).
+		Doc("将用户加入权限组").Body(data{}).Returns("")
 }
@@ -3,6 +3,7 @@ package perm
 import (
 	"ginWeb/model/authMode"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/database"
 	"github.com/gin-gonic/gin"
 )
@@ -96,12 +97,20 @@ func (p Permission) DeleteRole(c *gin.Context) {
 
 func (p Permission) RegisterRoute(r string, g *gin.RouterGroup) {
 	group := g.Group(r)
-	group.Handle("GET", "list", p.List)
-	group.Handle("GET", "create", p.Create)
-	group.Handle("GET", "delete", p.Delete)
-	group.Handle("POST", "update", p.Update)
-	group.Handle("GET", "createGroup", p.CreateGroup)
-	group.Handle("GET", "deleteGroup", p.DeleteGroup)
-	group.Handle("GET", "createRole", p.CreateRole)
-	group.Handle("GET", "deleteRole", p.DeleteRole)
+	openapi.Handle(group, "GET", "list", p.List).
+		Doc("所有权限").Returns([]authMode.Permissions{})
+	openapi.Handle(group, "GET", "create", p.Create).
+		Doc("创建权限").Body(PermissionData{}).Returns("")
+	openapi.Handle(group, "GET", "delete", p.Delete).
+		Doc("删除权限").Body(PermissionData{}).Returns("")
+	openapi.Handle(group, "POST", "update", p.Update).
+		Doc("更新权限，未实现").Body(UpdateReqData{})
+	openapi.Handle(group, "GET", "createGroup", p.CreateGroup).
+		Doc("创建权限组，未实现")
+	openapi.Handle(group, "GET", "deleteGroup", p.DeleteGroup).
+		Doc("删除权限组，未实现")
+	openapi.Handle(group, "GET", "createRole", p.CreateRole).
+		Doc("创建角色，未实现")
+	openapi.Handle(group, "GET", "deleteRole", p.DeleteRole).
+		Doc("删除角色，未实现")
 }
@@ -3,6 +3,7 @@ package server
 import (
 	"ginWeb/middleware"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/auth"
 	"github.com/gin-gonic/gin"
 	"time"
@@ -26,7 +27,14 @@ func (s Server) Check(c *gin.Context) {
 	})
 }
 
+// 校验参数
+type checkQuery struct {
+	Key string `form:"key"`
+}
+
 func (s Server) RegisterRoute(r string, g *gin.RouterGroup) {
-	g.Handle("GET", r+"/time", middleware.NewIpLimiter(120, 0, 0, g.BasePath()+r+"/time").HttpHandle, s.time)
-	g.Handle("GET", r+"/check", middleware.NewIpLimiter(120, 0, 0, g.BasePath()+r+"/check").HttpHandle, s.Check)
+	openapi.Handle(g, "GET", r+"/time", middleware.NewIpLimiter(120, 0, 0, g.BasePath()+r+"/time").HttpHandle, s.time).
+		Doc("服务器时间(ms)").Returns(int64(0))
+	openapi.Handle(g, "GET", r+"/check", middleware.NewIpLimiter(120, 0, 0, g.BasePath()+r+"/check").HttpHandle, s.Check).
+		Doc("返回key的哈希值，用于校验客户端").Query(checkQuery{}).Returns("")
 }
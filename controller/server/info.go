@@ -4,6 +4,7 @@ import (
 	"ginWeb/config"
 	"ginWeb/middleware"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/service/wes"
 	"ginWeb/service/wes/subscribe"
 	"ginWeb/service/wireguard"
@@ -19,10 +20,11 @@ type wgInfo struct {
 }
 
 type connInfo struct {
-	ServerTime  int64 `json:"serverTime"`
-	WsConnected int   `json:"wsConnected"`
-	WgPeers     int   `json:"wgPeers"`
-	Rooms       int   `json:"rooms"`
+	ServerTime  int64          `json:"serverTime"`
+	WsConnected int            `json:"wsConnected"`
+	WgPeers     int            `json:"wgPeers"`
+	Rooms       int            `json:"rooms"`
+	SendQueue   wes.QueueStats `json:"sendQueue"` // 发送队列统计
 }
 
 type InfoMessage struct{}
@@ -35,6 +37,7 @@ func (i InfoMessage) Connecting(ctx *gin.Context) {
 			WsConnected: wes.ConnManager.Count(),
 			WgPeers:     wireguard.WireguardManager.PeersCount(),
 			Rooms:       subscribe.Roomer.Size(),
+			SendQueue:   wes.ConnManager.QueueStats(),
 		},
 	})
 }
@@ -52,6 +55,8 @@ func (i InfoMessage) Wginfo(ctx *gin.Context) {
 
 func (i InfoMessage) RegisterRoute(r string, g *gin.RouterGroup) {
 	group := g.Group(r)
-	group.Handle("GET", "connecting", middleware.NewIndependentLimiter(1000, 0, 0).HttpHandle, i.Connecting)
-	group.Handle("GET", "wginfo", middleware.NewIndependentLimiter(1000, 0, 0).HttpHandle, i.Wginfo)
+	openapi.Handle(group, "GET", "connecting", middleware.NewIndependentLimiter(1000, 0, 0).HttpHandle, i.Connecting).
+		Doc("服务器连接统计").Returns(connInfo{})
+	openapi.Handle(group, "GET", "wginfo", middleware.NewIndependentLimiter(1000, 0, 0).HttpHandle, i.Wginfo).
+		Doc("wireguard服务端信息").Returns(wgInfo{})
 }
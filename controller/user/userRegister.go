@@ -2,8 +2,10 @@ package user
 
 import (
 	"ginWeb/config"
+	"ginWeb/middleware"
 	"ginWeb/model/systemMode"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/auth"
 	"ginWeb/utils/database"
 
@@ -71,3 +73,11 @@ func PublicRegister(ctx *gin.Context) {
 		Code: dataType.Success,
 	})
 }
+
+type Register struct {
+}
+
+func (r Register) RegisterRoute(route string, g *gin.RouterGroup) {
+	openapi.Handle(g, "POST", route, middleware.NewIpLimiter(10, 0, 0, route).HttpHandle, PublicRegister).
+		Doc("公开注册账号，需开启公开注册").Body(dataCreate{})
+}
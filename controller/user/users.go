@@ -4,10 +4,10 @@ import (
 	"ginWeb/middleware"
 	"ginWeb/model/systemMode"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/utils/auth"
 	"ginWeb/utils/database"
 	"ginWeb/utils/tools"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -86,17 +86,22 @@ type createPiecesResp struct {
 	Password string `json:"password"`
 }
 
+// 批量创建参数
+type createPiecesQuery struct {
+	Count int `form:"count" binding:"required,min=1,max=2000"`
+}
+
 // CreatePieces 批量创建账号
 func (u Users) CreatePieces(ctx *gin.Context) {
-	count := ctx.Query("count")
-	c, err := strconv.Atoi(count)
-	if err != nil || c <= 0 || c > 2000 {
+	var q createPiecesQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
 		ctx.AbortWithStatusJSON(200, dataType.JsonWrong{
 			Code:    dataType.WrongData,
-			Message: "wrong count",
+			Message: err.Error(),
 		})
 		return
 	}
+	c := q.Count
 	var newUsers []systemMode.User = make([]systemMode.User, c)
 	var result []createPiecesResp = make([]createPiecesResp, c)
 	tx := database.Db.Begin()
@@ -133,6 +138,8 @@ func (u Users) CreatePieces(ctx *gin.Context) {
 
 func (u Users) RegisterRoute(r string, router *gin.RouterGroup) {
 	g := router.Group(r)
-	g.Handle("POST", "update", u.Update)
-	g.Handle("GET", "createPieces", middleware.NewPermission([]string{"admin"}).HttpHandle, u.CreatePieces)
+	openapi.Handle(g, "POST", "update", u.Update).
+		Doc("更新账号信息，只更新传入的字段").Body(dataUpdate{})
+	openapi.Handle(openapi.Guard(g, middleware.NewPermission([]string{"admin"})), "GET", "createPieces", u.CreatePieces).
+		Doc("批量创建账号，返回账号和随机密码").Query(createPiecesQuery{}).Returns([]createPiecesResp{})
 }
@@ -1,7 +1,6 @@
 package ws
 
 import (
-	"encoding/json"
 	"ginWeb/service/dataType"
 	"ginWeb/service/wes"
 	"time"
@@ -10,18 +9,15 @@ import (
 type Base struct {
 }
 
-func (b Base) Ping(w *wes.WContext) {
-	if len(w.Request.Params) < 1 {
-		w.Result(dataType.WrongBody, "wrong ping body")
-		return
-	}
-	var dt int
-	err := json.Unmarshal(w.Request.Params[0], &dt)
-	if err != nil {
-		w.Result(dataType.WrongData, "wrong ping data: "+err.Error())
-		return
-	}
-	w.Result(dataType.Success, dt)
+// 心跳参数
+type pingParams struct {
+	Time int `json:"time"`
+}
+
+// Ping 原样返回客户端时间，用于测量延迟
+// params: [time: int]
+func (b Base) Ping(w *wes.WContext, p *pingParams) {
+	w.Result(dataType.Success, p.Time)
 }
 
 func (b Base) ResetLifetime(w *wes.WContext) {
@@ -38,10 +34,31 @@ func (b Base) ConnectUuid(w *wes.WContext) {
 	w.Result(dataType.Success, w.Conn.Uuid)
 }
 
+// 推送确认参数
+type ackParams struct {
+	Seq uint64 `json:"seq"`
+}
+
+// Ack 确认已处理的推送，seq及之前的推送不再重试，返回仍未确认的重要推送数量
+// params: [seq: int]
+func (b Base) Ack(w *wes.WContext, p *ackParams) {
+	if !w.Conn.Ack {
+		w.Result(dataType.WrongData, "ack is not enabled on this connection")
+		return
+	}
+	w.Result(dataType.Success, w.Conn.Acknowledge(p.Seq))
+}
+
 func (b Base) RegisterWSRoute(r string, g *wes.Group) {
 	group := g.Group(r)
-	group.Register("ping", b.Ping)
-	group.Register("time", b.ServerTime)
-	group.Register("connectUuid", b.ConnectUuid)
-	group.Register("resetLifetime", b.ResetLifetime)
+	group.Register("ping", wes.Bind(b.Ping)).
+		Doc("原样返回客户端时间，用于测量延迟").Accepts(pingParams{}).Returns(0)
+	group.Register("time", b.ServerTime).
+		Doc("服务器时间(ms)").Returns(int64(0))
+	group.Register("connectUuid", b.ConnectUuid).
+		Doc("当前连接uuid").Returns("")
+	group.Register("resetLifetime", b.ResetLifetime).
+		Doc("重置连接最长时间").Returns("")
+	group.Register("ack", wes.Bind(b.Ack)).
+		Doc("确认seq及之前的推送，返回仍未确认的重要推送数量").Accepts(ackParams{}).Returns(0)
 }
@@ -2,11 +2,19 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
+	"ginWeb/config"
 	"ginWeb/middleware"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/service/wes"
 	"ginWeb/service/wes/subscribe"
+	"ginWeb/utils/auth"
+	"ginWeb/utils/tools"
+	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,34 +22,163 @@ import (
 type ChannelController struct {
 }
 
-// SubHandle ws订阅事件接口
+// 订阅参数，有密码的频道使用对象形式
+type subParam struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// SubHandle ws订阅事件接口，name可使用通配符订阅层级频道，*匹配单个层级，#匹配零个或多个层级
+// params: [name: string | {name: string, password: string}, ...]
 func (c ChannelController) SubHandle(w *wes.WContext) {
 	if len(w.Request.Params) == 0 {
 		w.Result(dataType.WrongData, "without params")
 		return
 	}
 	var failedKeys = make([]string, 0)
-	for _, name := range w.Request.Params {
-		var n string
-		err := json.Unmarshal(name, &n)
-		if err != nil {
-			failedKeys = append(failedKeys, n)
+	var deniedKeys = make([]string, 0)
+	for _, raw := range w.Request.Params {
+		var param subParam
+		if json.Unmarshal(raw, &param.Name) != nil && json.Unmarshal(raw, &param) != nil {
+			failedKeys = append(failedKeys, string(raw))
 			continue
 		}
-		pub, ok := subscribe.Publishers.GetPub(n)
-		if ok {
-			_ = pub.Subscribe(w.Conn)
-		} else {
-			failedKeys = append(failedKeys, n)
+		if subscribe.IsPattern(param.Name) {
+			_, err := subscribe.Publishers.SubscribePattern(w.Conn, param.Name)
+			if err != nil && !errors.Is(err, subscribe.ErrAlreadySubscribed) {
+				failedKeys = append(failedKeys, param.Name)
+			}
+			continue
+		}
+		pub, ok := subscribe.Publishers.GetPub(param.Name)
+		if !ok {
+			failedKeys = append(failedKeys, param.Name)
+			continue
+		}
+		if pub.Subscribe(w.Conn, param.Password) != nil && !pub.IsSuber(w.Conn) {
+			deniedKeys = append(deniedKeys, param.Name)
 		}
 	}
 	if len(failedKeys) > 0 {
 		w.Result(dataType.NotFound, strings.Join(failedKeys, ","))
 		return
 	}
+	if len(deniedKeys) > 0 {
+		w.Result(dataType.DeniedByPermission, strings.Join(deniedKeys, ","))
+		return
+	}
+	w.Result(dataType.Success, "success")
+}
+
+// 创建频道权限中间件，满足其一即可
+func createChannelPermission() wes.Guard {
+	if perm := config.Conf.Server.Channel.CreatePermission; perm != "" {
+		return middleware.NewPermission([]string{}, []string{"admin", perm})
+	}
+	return middleware.NewPermission([]string{}, []string{"admin"})
+}
+
+// 是否可删除频道，管理员或频道创建者
+func canDeleteChannel(name string, userUuid string, perms []string) (bool, error) {
+	pub, ok := subscribe.Publishers.GetPub(name)
+	if !ok {
+		return false, errors.New("channel not found")
+	}
+	return pub.Creator() == userUuid || slices.Contains(perms, "admin"), nil
+}
+
+// 删除频道权限中间件，管理员或频道创建者，按参数中的频道名检查
+type channelOwnerGuard struct {
+}
+
+// Permissions creator为频道创建者
+func (g channelOwnerGuard) Permissions() []string {
+	return []string{"admin|creator"}
+}
+
+func (g channelOwnerGuard) HttpHandle(ctx *gin.Context) {
+	tokenS, _ := ctx.Get("token")
+	token, ok := tokenS.(*auth.Token)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NoToken, Message: "no token",
+		})
+		return
+	}
+	var q channelQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	allowed, err := canDeleteChannel(q.Name, token.UserUUID, token.Permission)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NotFound, Message: err.Error(),
+		})
+		return
+	}
+	if !allowed {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.DeniedByPermission, Message: "denied",
+		})
+	}
+}
+
+func (g channelOwnerGuard) WsHandle(w *wes.WContext) {
+	var p channelParams
+	if err := w.Bind(&p); err != nil {
+		w.Result(dataType.WrongBody, err.Error())
+		return
+	}
+	allowed, err := canDeleteChannel(p.Name, w.Conn.UserUuid, w.Conn.UserPermission)
+	if err != nil {
+		w.Result(dataType.NotFound, err.Error())
+		return
+	}
+	if !allowed {
+		w.Result(dataType.DeniedByPermission, "denied")
+	}
+}
+
+// 创建频道参数
+type createChannelParams struct {
+	Config subscribe.ChannelConfig `json:"config"`
+}
+
+// CreateChannel 创建频道
+// params: [config: subscribe.ChannelConfig]
+func (c ChannelController) CreateChannel(w *wes.WContext, p *createChannelParams) {
+	pub, err := subscribe.Publishers.CreateChannel(&p.Config, w.Conn.UserUuid)
+	if err != nil {
+		w.Result(dataType.AlreadyExist, err.Error())
+		return
+	}
+	w.Result(dataType.Success, pub.Info())
+}
+
+// 频道参数
+type channelParams struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// DeleteChannel 删除频道
+// params: [name: string]
+func (c ChannelController) DeleteChannel(w *wes.WContext, p *channelParams) {
+	err := subscribe.Publishers.DeleteChannel(p.Name)
+	if err != nil {
+		w.Result(dataType.WrongData, err.Error())
+		return
+	}
 	w.Result(dataType.Success, "success")
 }
 
+// ListChannel 所有频道
+func (c ChannelController) ListChannel(w *wes.WContext) {
+	w.Result(dataType.Success, subscribe.Publishers.List())
+}
+
 // UnsubHandle ws取消事件订阅接口
 func (c ChannelController) UnsubHandle(w *wes.WContext) {
 	if len(w.Request.Params) == 0 {
@@ -56,6 +193,12 @@ func (c ChannelController) UnsubHandle(w *wes.WContext) {
 			failedKeys = append(failedKeys, n)
 			continue
 		}
+		if subscribe.IsPattern(n) {
+			if subscribe.Publishers.UnsubscribePattern(w.Conn, n) != nil {
+				failedKeys = append(failedKeys, n)
+			}
+			continue
+		}
 		pub, ok := subscribe.Publishers.GetPub(n)
 		if ok {
 			_ = pub.UnSubscribe(w.Conn)
@@ -70,41 +213,283 @@ func (c ChannelController) UnsubHandle(w *wes.WContext) {
 	w.Result(dataType.Success, "success")
 }
 
+// 频道消息参数
+type broadcastParams struct {
+	Name    string `json:"name" validate:"required"`
+	Message string `json:"message"`
+}
+
 // Broadcast 向频道发送消息
-func (c ChannelController) Broadcast(w *wes.WContext) {
+// params: [name: string, message: string]
+func (c ChannelController) Broadcast(w *wes.WContext, p *broadcastParams) {
+	pub, ok := subscribe.Publishers.GetPub(p.Name)
+	if !ok || pub.IsSuber(w.Conn) == false {
+		w.Result(dataType.NotFound, "not found pub or not suber")
+		return
+	}
+	err := pub.Message(p.Message, w.Conn)
+	if err != nil {
+		w.Result(moderationCode(err), err.Error())
+		return
+	}
+	w.Result(dataType.Success, "success")
+}
+
+// 禁言参数
+type muteParams struct {
+	Name     string `json:"name" validate:"required"`
+	UserUuid string `json:"userUuid" validate:"required"`
+	Duration int64  `json:"duration" validate:"omitempty,gte=0"`
+	Reason   string `json:"reason" validate:"omitempty,max=255"`
+}
+
+// Mute 禁言频道用户
+// params: [name: string, userUuid: string, duration?: int, reason?: string]，duration单位为秒，0为永久
+func (c ChannelController) Mute(w *wes.WContext, p *muteParams) {
+	pub, ok := subscribe.Publishers.GetPub(p.Name)
+	if !ok {
+		w.Result(dataType.NotFound, "not found pub")
+		return
+	}
+	w.Result(dataType.Success, pub.Mute(p.UserUuid, time.Duration(p.Duration)*time.Second, p.Reason, w.Conn.UserUuid))
+}
+
+// 解除禁言参数
+type unmuteParams struct {
+	Name     string `json:"name" validate:"required"`
+	UserUuid string `json:"userUuid" validate:"required"`
+}
+
+// Unmute 解除频道用户禁言
+// params: [name: string, userUuid: string]
+func (c ChannelController) Unmute(w *wes.WContext, p *unmuteParams) {
+	pub, ok := subscribe.Publishers.GetPub(p.Name)
+	if !ok {
+		w.Result(dataType.NotFound, "not found pub")
+		return
+	}
+	if !pub.Unmute(p.UserUuid) {
+		w.Result(dataType.NotFound, "user not muted")
+		return
+	}
+	w.Result(dataType.Success, "success")
+}
+
+// MuteList 频道禁言列表
+// params: [name: string]
+func (c ChannelController) MuteList(w *wes.WContext, p *channelParams) {
+	pub, ok := subscribe.Publishers.GetPub(p.Name)
+	if !ok {
+		w.Result(dataType.NotFound, "not found pub")
+		return
+	}
+	w.Result(dataType.Success, pub.Mutes())
+}
+
+// HttpMute 禁言频道用户
+func (c ChannelController) HttpMute(ctx *gin.Context) {
+	var q muteQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	pub, ok := subscribe.Publishers.GetPub(q.Name)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NotFound, Message: "not found pub",
+		})
+		return
+	}
+	tokenS, _ := ctx.Get("token")
+	token, ok := tokenS.(*auth.Token)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NoToken, Message: "no token",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, dataType.JsonRes{
+		Code: dataType.Success,
+		Data: pub.Mute(q.User, time.Duration(q.Duration)*time.Second, q.Reason, token.UserUUID),
+	})
+}
+
+// HttpUnmute 解除频道用户禁言
+func (c ChannelController) HttpUnmute(ctx *gin.Context) {
+	var q unmuteQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	pub, ok := subscribe.Publishers.GetPub(q.Name)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NotFound, Message: "not found pub",
+		})
+		return
+	}
+	if !pub.Unmute(q.User) {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NotFound, Message: "user not muted",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, dataType.JsonRes{
+		Code: dataType.Success, Data: "success",
+	})
+}
 
-	if len(w.Request.Params) != 2 {
-		w.Result(dataType.WrongBody, "invalid param")
+// HttpMuteList 频道禁言列表
+func (c ChannelController) HttpMuteList(ctx *gin.Context) {
+	var q channelQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	pub, ok := subscribe.Publishers.GetPub(q.Name)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NotFound, Message: "not found pub",
+		})
 		return
 	}
-	var name string
-	var msg string
-	err := json.Unmarshal(w.Request.Params[0], &name)
+	ctx.JSON(http.StatusOK, dataType.JsonRes{
+		Code: dataType.Success, Data: pub.Mutes(),
+	})
+}
+
+// HttpCreateChannel 创建频道
+func (c ChannelController) HttpCreateChannel(ctx *gin.Context) {
+	body, err := ctx.GetRawData()
 	if err != nil {
-		w.Result(dataType.WrongBody, "invalid name")
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongBody, Message: err.Error(),
+		})
 		return
 	}
-	err = json.Unmarshal(w.Request.Params[1], &msg)
+	var conf subscribe.ChannelConfig
+	err = tools.ShouldBindJson(body, &conf)
 	if err != nil {
-		w.Result(dataType.WrongBody, "invalid msg")
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongBody, Message: err.Error(),
+		})
 		return
 	}
-	pub, ok := subscribe.Publishers.GetPub(name)
-	if !ok || pub.IsSuber(w.Conn) == false {
-		w.Result(dataType.NotFound, "not found pub or not suber")
+	tokenS, _ := ctx.Get("token")
+	token, ok := tokenS.(*auth.Token)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.NoToken, Message: "no token",
+		})
 		return
 	}
-	pub.Message(msg, w.Conn)
-	w.Result(dataType.Success, "success")
+	pub, err := subscribe.Publishers.CreateChannel(&conf, token.UserUUID)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.AlreadyExist, Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, dataType.JsonRes{
+		Code: dataType.Success, Data: pub.Info(),
+	})
 }
 
-func (c ChannelController) RegisterRoute(r string, g *gin.RouterGroup) {}
+// HttpDeleteChannel 删除频道
+func (c ChannelController) HttpDeleteChannel(ctx *gin.Context) {
+	var q channelQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	err := subscribe.Publishers.DeleteChannel(q.Name)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, dataType.JsonRes{
+		Code: dataType.Success, Data: "success",
+	})
+}
+
+// HttpListChannel 所有频道
+func (c ChannelController) HttpListChannel(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dataType.JsonRes{
+		Code: dataType.Success, Data: subscribe.Publishers.List(),
+	})
+}
+
+// 频道查询参数
+type channelQuery struct {
+	Name string `form:"name" binding:"required"`
+}
+
+// 禁言查询参数
+type muteQuery struct {
+	Name     string `form:"name" binding:"required"`
+	User     string `form:"user" binding:"required"`
+	Duration int64  `form:"duration" binding:"gte=0"` // 单位为秒，0为永久
+	Reason   string `form:"reason"`
+}
+
+// 解除禁言查询参数
+type unmuteQuery struct {
+	Name string `form:"name" binding:"required"`
+	User string `form:"user" binding:"required"`
+}
+
+// 管理员或频道管理员权限
+var moderatorPermission = middleware.NewPermission([]string{}, []string{"admin", "moderator"})
+
+func (c ChannelController) RegisterRoute(r string, g *gin.RouterGroup) {
+	group := g.Group(r)
+	openapi.Handle(group, "GET", "list", c.HttpListChannel).
+		Doc("所有频道").Returns([]subscribe.ChannelInfo{})
+	openapi.Handle(openapi.Guard(group, createChannelPermission()), "POST", "create", c.HttpCreateChannel).
+		Doc("创建频道").Body(subscribe.ChannelConfig{}).Returns(subscribe.ChannelInfo{})
+	openapi.Handle(openapi.Guard(group, channelOwnerGuard{}), "GET", "delete", c.HttpDeleteChannel).
+		Doc("删除频道，管理员或频道创建者可用").Query(channelQuery{}).Returns("")
+	moderator := openapi.Guard(group, moderatorPermission)
+	openapi.Handle(moderator, "GET", "mute", c.HttpMute).
+		Doc("禁言频道用户，禁言仅保存在内存中，服务重启后失效").Query(muteQuery{}).Returns(subscribe.MuteInfo{})
+	openapi.Handle(moderator, "GET", "unmute", c.HttpUnmute).
+		Doc("解除频道用户禁言").Query(unmuteQuery{}).Returns("")
+	openapi.Handle(moderator, "GET", "mutes", c.HttpMuteList).
+		Doc("频道禁言列表").Query(channelQuery{}).Returns([]subscribe.MuteInfo{})
+}
 
 func (c ChannelController) RegisterWSRoute(r string, g *wes.Group) {
 
 	group := g.Group(r)
+	authed := g.Group(r, middleware.AuthMiddle.WsHandle)
 
-	group.Register("broadcast", middleware.AuthMiddle.WsHandle, c.Broadcast)
-	group.Register("subscribe", c.SubHandle)
-	group.Register("unsubscribe", c.UnsubHandle)
+	authed.Register("broadcast", wes.Bind(c.Broadcast)).
+		Doc("向已订阅的频道发送消息").Accepts(broadcastParams{}).Returns("")
+	group.Register("subscribe", c.SubHandle).
+		Doc("订阅频道，name可使用通配符，*匹配单个层级，#匹配零个或多个层级").AcceptsEach("channel", subParam{}).Returns("")
+	group.Register("unsubscribe", c.UnsubHandle).
+		Doc("取消订阅频道").AcceptsEach("name", "").Returns("")
+	moderator := authed.Guard(moderatorPermission)
+	moderator.Register("mute", wes.Bind(c.Mute)).
+		Doc("禁言频道用户，duration单位为秒，0为永久，禁言仅保存在内存中，服务重启后失效").Accepts(muteParams{}).Returns(subscribe.MuteInfo{})
+	moderator.Register("unmute", wes.Bind(c.Unmute)).
+		Doc("解除频道用户禁言").Accepts(unmuteParams{}).Returns("")
+	moderator.Register("mutes", wes.Bind(c.MuteList)).
+		Doc("频道禁言列表").Accepts(channelParams{}).Returns([]subscribe.MuteInfo{})
+	authed.Guard(createChannelPermission()).Register("create", wes.Bind(c.CreateChannel)).
+		Doc("创建频道").Accepts(createChannelParams{}).Returns(subscribe.ChannelInfo{})
+	authed.Guard(channelOwnerGuard{}).Register("delete", wes.Bind(c.DeleteChannel)).
+		Doc("删除频道，管理员或频道创建者可用").Accepts(channelParams{}).Returns("")
+	group.Register("list", c.ListChannel).
+		Doc("所有频道").Returns([]subscribe.ChannelInfo{})
 }
@@ -0,0 +1,85 @@
+package ws
+
+import (
+	"errors"
+	"ginWeb/middleware"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/service/wes/direct"
+)
+
+type DirectController struct {
+}
+
+// 私信参数
+type sendParams struct {
+	UserUuid string `json:"userUuid" validate:"required"`
+	Text     string `json:"text" validate:"required"`
+}
+
+// 私信发送结果
+type sendResult struct {
+	MessageId string `json:"messageId"`
+	Timestamp int64  `json:"timestamp"`
+	Online    bool   `json:"online"` // 接收者是否在线，送达以publish.dm.delivered回执为准
+}
+
+// Send 发送私信
+// params: [userUuid: string, text: string]
+func (d DirectController) Send(w *wes.WContext, p *sendParams) {
+	msg, online, err := direct.Messenger.Send(w.Ctx(), w.Conn, p.UserUuid, p.Text)
+	switch {
+	case err == nil:
+	case errors.Is(err, direct.ErrBlocked):
+		w.Result(dataType.DeniedByPermission, err.Error())
+		return
+	case errors.Is(err, direct.ErrUserNotFound):
+		w.Result(dataType.NotFound, err.Error())
+		return
+	case errors.Is(err, direct.ErrSelfMessage):
+		w.Result(dataType.WrongData, err.Error())
+		return
+	default:
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, sendResult{MessageId: msg.MessageId, Timestamp: msg.Timestamp, Online: online})
+}
+
+// 私信id列表参数
+type messageIdsParams struct {
+	MessageIds []string `json:"messageIds" validate:"required,min=1,dive,required" wes:"variadic"`
+}
+
+// Received 确认收到私信
+// params: [messageId: string, ...]
+func (d DirectController) Received(w *wes.WContext, p *messageIdsParams) {
+	count, err := direct.Messenger.Received(w.Ctx(), w.Conn, p.MessageIds...)
+	if err != nil {
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, count)
+}
+
+// Read 标记私信已读
+// params: [messageId: string, ...]
+func (d DirectController) Read(w *wes.WContext, p *messageIdsParams) {
+	count, err := direct.Messenger.Read(w.Ctx(), w.Conn, p.MessageIds...)
+	if err != nil {
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, count)
+}
+
+func (d DirectController) RegisterWSRoute(r string, g *wes.Group) {
+	group := g.Group(r)
+	group.Use(middleware.AuthMiddle.WsHandle)
+	group.Register("send", wes.Bind(d.Send)).
+		Doc("发送私信，对方在线时直接投递，对方确认收到后推送送达回执").Accepts(sendParams{}).Returns(sendResult{})
+	group.Register("received", wes.Bind(d.Received)).
+		Doc("确认收到私信，返回标记数量，未确认的私信在下次连接时重新投递").Accepts(messageIdsParams{}).Returns(0)
+	group.Register("read", wes.Bind(d.Read)).
+		Doc("标记私信已读，返回标记数量").Accepts(messageIdsParams{}).Returns(0)
+}
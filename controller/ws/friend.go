@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"errors"
+	"ginWeb/middleware"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/service/wes/friend"
+	"ginWeb/service/wes/subscribe"
+)
+
+type FriendController struct {
+}
+
+// List 好友列表及在线状态
+// params: []
+func (f FriendController) List(w *wes.WContext) {
+	friends, err := friend.Friends.List(w.Conn.UserUuid)
+	if err != nil {
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, friends)
+}
+
+// 邀请好友参数
+type inviteParams struct {
+	UserUuid string `json:"userUuid" validate:"required"`
+}
+
+// Invite 邀请好友进入自己所在的房间，返回好友是否在线
+// params: [userUuid: string]
+func (f FriendController) Invite(w *wes.WContext, p *inviteParams) {
+	online, err := friend.Friends.Invite(w.Conn, p.UserUuid)
+	switch {
+	case err == nil:
+	case errors.Is(err, friend.ErrNotFriend), errors.Is(err, friend.ErrNotOwner):
+		w.Result(dataType.DeniedByPermission, err.Error())
+		return
+	case errors.Is(err, friend.ErrNotInRoom):
+		w.Result(dataType.NotFound, err.Error())
+		return
+	default:
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, online)
+}
+
+func (f FriendController) RegisterWSRoute(r string, g *wes.Group) {
+	group := g.Group(r)
+	group.Use(middleware.AuthMiddle.WsHandle)
+	group.Register("list", f.List).
+		Doc("好友列表及在线状态").Returns([]subscribe.PresenceInfo{})
+	group.Register("invite", wes.Bind(f.Invite)).
+		Doc("邀请好友进入自己所在的房间，返回好友是否在线，非房主只能邀请进入公开房间").Accepts(inviteParams{}).Returns(false)
+}
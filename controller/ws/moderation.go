@@ -0,0 +1,76 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"ginWeb/middleware"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/service/wes/moderate"
+)
+
+// 消息发送错误对应的状态码
+func moderationCode(err error) int {
+	switch {
+	case errors.Is(err, moderate.ErrFlood):
+		return dataType.TooManyRequests
+	case errors.Is(err, moderate.ErrTooLong), errors.Is(err, moderate.ErrForbidden):
+		return dataType.MessageRejected
+	default:
+		return dataType.Unknown
+	}
+}
+
+type ModerationController struct {
+}
+
+// 消息id，房间消息id为数字，其余为字符串
+type messageId string
+
+func (m *messageId) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*m = messageId(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*m = messageId(s)
+	return nil
+}
+
+// 举报参数，target为房间uuid或频道名，私信为空
+type reportParams struct {
+	Type      string    `json:"type" validate:"required,oneof=room channel dm"`
+	Target    string    `json:"target" validate:"required_unless=Type dm"`
+	MessageId messageId `json:"messageId" validate:"required"`
+	Reason    string    `json:"reason" validate:"omitempty,max=255"`
+}
+
+// Report 举报房间、频道消息或私信，举报者需是消息的接收者
+// params: [type: "room" | "channel" | "dm", target: string, messageId: int | string, reason?: string]
+func (m ModerationController) Report(w *wes.WContext, p *reportParams) {
+	err := moderate.Moderation.Report(w.Ctx(), w.Conn, p.Type, p.Target, string(p.MessageId), p.Reason)
+	if errors.Is(err, moderate.ErrNotFound) {
+		w.Result(dataType.NotFound, err.Error())
+		return
+	}
+	if errors.Is(err, moderate.ErrNotRecipient) {
+		w.Result(dataType.DeniedByPermission, err.Error())
+		return
+	}
+	if err != nil {
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, "success")
+}
+
+func (m ModerationController) RegisterWSRoute(r string, g *wes.Group) {
+	group := g.Group(r)
+	group.Use(middleware.AuthMiddle.WsHandle)
+	group.Register("report", wes.Bind(m.Report)).
+		Doc("举报房间、频道消息或私信，房间消息使用消息记录中的id，私信使用私信uuid").Accepts(reportParams{}).Returns("")
+}
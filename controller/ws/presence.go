@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"ginWeb/middleware"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/service/wes/subscribe"
+)
+
+type PresenceController struct {
+}
+
+// 用户uuid列表参数，单次最多100个
+type userUuidsParams struct {
+	UserUuids []string `json:"userUuids" validate:"required,min=1,max=100,dive,required" wes:"variadic"`
+}
+
+// 取消订阅参数，不传入时取消全部
+type unwatchParams struct {
+	UserUuids []string `json:"userUuids" validate:"omitempty,max=100,dive,required" wes:"variadic"`
+}
+
+// Query 查询用户在线状态
+// params: [userUuid: string, ...]
+func (p PresenceController) Query(w *wes.WContext, params *userUuidsParams) {
+	w.Result(dataType.Success, subscribe.Presences.QueryFor(w.Conn.UserUuid, params.UserUuids...))
+}
+
+// 设置状态参数
+type presenceParams struct {
+	State string `json:"state"`
+}
+
+// Set 设置自身状态
+// params: [state: "online" | "away"]
+func (p PresenceController) Set(w *wes.WContext, params *presenceParams) {
+	state := params.State
+	if state != subscribe.PresenceOnline && state != subscribe.PresenceAway {
+		w.Result(dataType.WrongData, "state must be online or away")
+		return
+	}
+	if err := subscribe.Presences.SetAway(w.Conn.UserUuid, state == subscribe.PresenceAway); err != nil {
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, "ok")
+}
+
+// Subscribe 订阅用户在线状态变化，返回当前状态
+// params: [userUuid: string, ...]
+func (p PresenceController) Subscribe(w *wes.WContext, params *userUuidsParams) {
+	subscribe.Presences.Watch(w.Conn, params.UserUuids...)
+	w.Result(dataType.Success, subscribe.Presences.QueryFor(w.Conn.UserUuid, params.UserUuids...))
+}
+
+// Unsubscribe 取消订阅用户在线状态变化，不传入参数时取消全部
+// params: [userUuid: string, ...]
+func (p PresenceController) Unsubscribe(w *wes.WContext, params *unwatchParams) {
+	subscribe.Presences.Unwatch(w.Conn, params.UserUuids...)
+	w.Result(dataType.Success, "ok")
+}
+
+func (p PresenceController) RegisterWSRoute(r string, g *wes.Group) {
+	group := g.Group(r)
+	group.Use(middleware.AuthMiddle.WsHandle)
+	group.Register("query", wes.Bind(p.Query)).
+		Doc("查询用户在线状态，拉黑了自己的用户显示为离线").Accepts(userUuidsParams{}).Returns([]subscribe.PresenceInfo{})
+	group.Register("set", wes.Bind(p.Set)).
+		Doc("设置自身状态，online或away").Accepts(presenceParams{}).Returns("")
+	group.Register("subscribe", wes.Bind(p.Subscribe)).
+		Doc("订阅用户在线状态变化，返回当前状态，拉黑了自己的用户不会推送").Accepts(userUuidsParams{}).Returns([]subscribe.PresenceInfo{})
+	group.Register("unsubscribe", wes.Bind(p.Unsubscribe)).
+		Doc("取消订阅用户在线状态变化，不传入参数时取消全部").Accepts(unwatchParams{}).Returns("")
+}
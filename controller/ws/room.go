@@ -2,16 +2,16 @@ package ws
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"ginWeb/middleware"
 	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
 	"ginWeb/service/wes"
 	"ginWeb/service/wes/subscribe"
 	"ginWeb/utils/tools"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 )
 
 type RoomController struct {
@@ -26,90 +26,59 @@ func checkEd25519KeyLength(key string) bool {
 	return len(byteKey) == 32
 }
 
+func init() {
+	tools.RegisterValidation("ed25519", func(fl validator.FieldLevel) bool {
+		return checkEd25519KeyLength(fl.Field().String())
+	})
+}
+
+// 创建房间参数
+type createRoomParams struct {
+	Config    subscribe.RoomConfig `json:"config"`
+	PublicKey string               `json:"publicKey" validate:"required,ed25519"`
+	UdpPort   int                  `json:"udpPort" validate:"required,gte=1,lte=65535"`
+}
+
+// 创建房间结果
+type createRoomResult struct {
+	RoomId string               `json:"roomId"`
+	Mates  []subscribe.MateInfo `json:"mates"`
+	Link   string               `json:"link"`
+}
+
 // CreateRoom 创建房间
 // params: [config: subscribe.RoomConfig, publicKey: string, udpPort: int]
-func (r RoomController) CreateRoom(w *wes.WContext) {
-	if len(w.Request.Params) != 3 {
-		w.Result(dataType.WrongBody, "invalid params")
-		return
-	}
-	var conf subscribe.RoomConfig
-	var publicKey string
-	err := tools.ShouldBindJson(w.Request.Params[0], &conf)
-	if err != nil {
-		w.Result(dataType.WrongBody, err.Error())
-		return
-	}
-	err = json.Unmarshal(w.Request.Params[1], &publicKey)
-	if err != nil || !checkEd25519KeyLength(publicKey) {
-		w.Result(dataType.WrongBody, "invalid public key")
-		return
-	}
-	var udpPort int
-	err = json.Unmarshal(w.Request.Params[2], &udpPort)
-	if err != nil || udpPort <= 0 || udpPort > 65535 {
-		w.Result(dataType.WrongBody, "invalided udp port")
-		return
-	}
-	room, err := subscribe.Roomer.NewRoom(w.Conn, &conf, publicKey, udpPort)
+func (r RoomController) CreateRoom(w *wes.WContext, p *createRoomParams) {
+	room, err := subscribe.Roomer.NewRoom(w.Conn, &p.Config, p.PublicKey, p.UdpPort)
 	if err != nil {
 		w.Result(dataType.Unknown, err.Error())
 		return
 	}
-	type respData struct {
-		RoomId string               `json:"roomId"`
-		Mates  []subscribe.MateInfo `json:"mates"`
-		Link   string               `json:"link"`
-	}
-	w.Result(dataType.Success, respData{RoomId: room.UUID(), Mates: room.Mates(), Link: room.Link})
+	w.Result(dataType.Success, createRoomResult{RoomId: room.UUID(), Mates: room.Mates(), Link: room.Link})
+}
+
+// 进入房间参数
+type inRoomParams struct {
+	RoomId    string `json:"roomId" validate:"required"`
+	PublicKey string `json:"publicKey" validate:"required,ed25519"`
+	UdpPort   int    `json:"udpPort" validate:"required,gte=1,lte=65535"`
+	Password  string `json:"password" validate:"omitempty,max=16"`
 }
 
 // GetInRoom 进入房间
 // params: [rooId: string, publicKey: string, udpPort: int, password?: string]
-func (r RoomController) GetInRoom(w *wes.WContext) {
-	if len(w.Request.Params) < 3 {
-		w.Result(dataType.WrongBody, "invalid params")
-		return
-	}
-	var roomId string
-	var publicKey string
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
-		return
-	}
-	err = json.Unmarshal(w.Request.Params[1], &publicKey)
-	if err != nil || !checkEd25519KeyLength(publicKey) {
-		w.Result(dataType.WrongBody, "invalided public key")
-		return
-	}
-	room, ok := subscribe.Roomer.Get(roomId)
+func (r RoomController) GetInRoom(w *wes.WContext, p *inRoomParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
-		w.Result(dataType.WrongBody, "room not found")
+		w.Result(dataType.NotFound, "room not found")
 		return
 	}
-	var password = ""
-	if len(w.Request.Params) > 3 {
-		var p string
-		err := json.Unmarshal(w.Request.Params[3], &p)
-		if err != nil || len(p) > 16 {
-			w.Result(dataType.WrongBody, "invalided password")
-			return
-		}
-		password = p
-	}
 	// 房间有密码且参数不为房间链接时才进行密码检测
-	if room.Config.Password != nil && room.Link != roomId && password != *room.Config.Password {
+	if room.Config.Password != nil && room.Link != p.RoomId && p.Password != *room.Config.Password {
 		w.Result(dataType.DeniedByPermission, "invalid password")
 		return
 	}
-	var udpPort int
-	err = json.Unmarshal(w.Request.Params[2], &udpPort)
-	if err != nil || udpPort <= 0 || udpPort > 65535 {
-		w.Result(dataType.WrongBody, "invalided udp port")
-		return
-	}
-	err = room.Subscribe(w.Conn, publicKey, udpPort)
+	err := room.Subscribe(w.Conn, p.PublicKey, p.UdpPort)
 	if err != nil {
 		w.Result(dataType.Unknown, "subscribe failed: "+err.Error())
 		return
@@ -117,25 +86,20 @@ func (r RoomController) GetInRoom(w *wes.WContext) {
 	w.Result(dataType.Success, room.Mates())
 }
 
+// 只包含房间id的参数
+type roomParams struct {
+	RoomId string `json:"roomId" validate:"required"`
+}
+
 // GetOutRoom 退出房间
 // params: [roomId: string]
-func (r RoomController) GetOutRoom(w *wes.WContext) {
-	if len(w.Request.Params) != 1 {
-		w.Result(dataType.WrongBody, "invalid params")
-		return
-	}
-	var roomId string
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
-		return
-	}
-	room, ok := subscribe.Roomer.Get(roomId)
+func (r RoomController) GetOutRoom(w *wes.WContext, p *roomParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
 		w.Result(dataType.NotFound, "room not found")
 		return
 	}
-	err = room.UnSubscribe(w.Conn)
+	err := room.UnSubscribe(w.Conn)
 	if err != nil {
 		w.Result(dataType.Unknown, "subscribe failed")
 		return
@@ -145,18 +109,8 @@ func (r RoomController) GetOutRoom(w *wes.WContext) {
 
 // CloseRoom 关闭房间
 // params: [roomId: string]
-func (r RoomController) CloseRoom(w *wes.WContext) {
-	if len(w.Request.Params) != 1 {
-		w.Result(dataType.WrongBody, "invalid params")
-		return
-	}
-	var roomId string
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
-		return
-	}
-	room, ok := subscribe.Roomer.Get(roomId)
+func (r RoomController) CloseRoom(w *wes.WContext, p *roomParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
 		w.Result(dataType.NotFound, "room not found")
 		return
@@ -169,47 +123,32 @@ func (r RoomController) CloseRoom(w *wes.WContext) {
 	w.Result(dataType.Success, "success")
 }
 
+// 房间禁止进入参数
+type forbiddenParams struct {
+	RoomId    string `json:"roomId" validate:"required"`
+	Forbidden bool   `json:"forbidden"`
+}
+
 // ForbiddenRoom 房间禁止进入
 // params: [rooId: string, stat: bool]
-func (r RoomController) ForbiddenRoom(w *wes.WContext) {
-	if len(w.Request.Params) != 2 {
-		w.Result(dataType.WrongBody, "invalid params")
-		return
-	}
-	var roomId string
-	var forbidden bool
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	err2 := json.Unmarshal(w.Request.Params[1], &forbidden)
-	if err != nil || err2 != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
-		return
-	}
-	room, ok := subscribe.Roomer.Get(roomId)
+func (r RoomController) ForbiddenRoom(w *wes.WContext, p *forbiddenParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
 		w.Result(dataType.NotFound, "room not found")
+		return
 	}
 	if room.OwnerUuid() != w.Conn.UserUuid {
 		w.Result(dataType.DeniedByPermission, "you are not room owner")
 		return
 	}
-	room.Forbidden(forbidden)
+	room.Forbidden(p.Forbidden)
 	w.Result(dataType.Success, "success")
 }
 
 // RoomMate 获取房间成员
 // params: [roomId: string]
-func (r RoomController) RoomMate(w *wes.WContext) {
-	if len(w.Request.Params) != 1 {
-		w.Result(dataType.WrongBody, "invalid params")
-		return
-	}
-	var roomId string
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
-		return
-	}
-	room, ok := subscribe.Roomer.Get(roomId)
+func (r RoomController) RoomMate(w *wes.WContext, p *roomParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
 		w.Result(dataType.NotFound, "room not found")
 		return
@@ -221,18 +160,31 @@ func (r RoomController) RoomMate(w *wes.WContext) {
 	w.Result(dataType.Success, room.Mates())
 }
 
-func (r RoomController) Link(w *wes.WContext) {
-	if len(w.Request.Params) != 1 {
-		w.Result(dataType.WrongBody, "invalid params")
+// Link 获取房间链接
+// params: [roomId: string]
+func (r RoomController) Link(w *wes.WContext, p *roomParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
+	if !ok {
+		w.Result(dataType.NotFound, "room not found")
 		return
 	}
-	var roomId string
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
+	if room.OwnerUuid() != w.Conn.UserUuid {
+		w.Result(dataType.DeniedByPermission, "you are not room owner")
 		return
 	}
-	room, ok := subscribe.Roomer.Get(roomId)
+	w.Result(dataType.Success, room.Link)
+}
+
+// 踢出成员参数
+type kickParams struct {
+	RoomId     string `json:"roomId" validate:"required"`
+	TargetUuid string `json:"targetUuid" validate:"required"`
+}
+
+// KickMember 踢出房间成员
+// params: [roomId: string, targetUuid: string]
+func (r RoomController) KickMember(w *wes.WContext, p *kickParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
 		w.Result(dataType.NotFound, "room not found")
 		return
@@ -241,21 +193,37 @@ func (r RoomController) Link(w *wes.WContext) {
 		w.Result(dataType.DeniedByPermission, "you are not room owner")
 		return
 	}
-	w.Result(dataType.Success, room.Link)
+	err := room.KickMember(w.Conn, p.TargetUuid)
+	if err != nil {
+		w.Result(dataType.Unknown, err.Error())
+		return
+	}
+	w.Result(dataType.Success, "success")
 }
 
-func (r RoomController) KickMember(w *wes.WContext) {
-	if len(w.Request.Params) != 2 {
-		w.Result(dataType.WrongBody, "invalid params")
+// BanMember 封禁用户，被封禁的用户不可再进入房间
+// params: [roomId: string, targetUuid: string]
+func (r RoomController) BanMember(w *wes.WContext, p *kickParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
+	if !ok {
+		w.Result(dataType.NotFound, "room not found")
 		return
 	}
-	var roomId string
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
+	if room.OwnerUuid() != w.Conn.UserUuid {
+		w.Result(dataType.DeniedByPermission, "you are not room owner")
 		return
 	}
-	room, ok := subscribe.Roomer.Get(roomId)
+	if err := room.BanMember(w.Conn, p.TargetUuid); err != nil {
+		w.Result(dataType.WrongData, err.Error())
+		return
+	}
+	w.Result(dataType.Success, "success")
+}
+
+// UnbanMember 解除封禁
+// params: [roomId: string, targetUuid: string]
+func (r RoomController) UnbanMember(w *wes.WContext, p *kickParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
 		w.Result(dataType.NotFound, "room not found")
 		return
@@ -264,40 +232,70 @@ func (r RoomController) KickMember(w *wes.WContext) {
 		w.Result(dataType.DeniedByPermission, "you are not room owner")
 		return
 	}
-	var targetUuid string
-	err = json.Unmarshal(w.Request.Params[1], &targetUuid)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided target uuid")
+	if err := room.UnbanMember(w.Conn, p.TargetUuid); err != nil {
+		w.Result(dataType.WrongData, err.Error())
 		return
 	}
-	err = room.KickMember(w.Conn, targetUuid)
-	if err != nil {
-		w.Result(dataType.Unknown, err.Error())
+	w.Result(dataType.Success, "success")
+}
+
+// Bans 封禁名单
+// params: [roomId: string]
+func (r RoomController) Bans(w *wes.WContext, p *roomParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
+	if !ok {
+		w.Result(dataType.NotFound, "room not found")
 		return
 	}
-	w.Result(dataType.Success, "success")
+	if room.OwnerUuid() != w.Conn.UserUuid {
+		w.Result(dataType.DeniedByPermission, "you are not room owner")
+		return
+	}
+	w.Result(dataType.Success, room.Bans())
+}
+
+// 发送消息参数
+type roomMessageParams struct {
+	RoomId  string `json:"roomId" validate:"required"`
+	Message string `json:"message"`
 }
 
 // RoomMessage 发送消息
 // params: [roomId: string, message: string]
-func (r RoomController) RoomMessage(w *wes.WContext) {
-	if len(w.Request.Params) != 2 {
-		w.Result(dataType.WrongBody, "invalid params")
+func (r RoomController) RoomMessage(w *wes.WContext, p *roomMessageParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
+	if !ok {
+		w.Result(dataType.NotFound, "room not found")
 		return
 	}
-	var roomId string
-	err := json.Unmarshal(w.Request.Params[0], &roomId)
-	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room id")
+	if !room.IsSuber(w.Conn) {
+		w.Result(dataType.DeniedByPermission, "not in room")
 		return
 	}
-	var message string
-	err = json.Unmarshal(w.Request.Params[1], &message)
+	id, err := room.Message(p.Message, w.Conn)
 	if err != nil {
-		w.Result(dataType.WrongBody, "invalided room message")
+		w.Result(moderationCode(err), err.Error())
+		return
+	}
+	// 未开启消息记录时保持原有的返回值
+	if !subscribe.HistoryEnabled() {
+		w.Result(dataType.Success, "success")
 		return
 	}
-	room, ok := subscribe.Roomer.Get(roomId)
+	w.Result(dataType.Success, id)
+}
+
+// 历史消息查询参数
+type historyParams struct {
+	RoomId    string `json:"roomId" validate:"required"`
+	MessageId int64  `json:"messageId" validate:"omitempty,gte=0"`
+	Limit     int    `json:"limit" validate:"omitempty,gte=0"`
+}
+
+// RoomHistory 分页获取历史消息，beforeId为0时从最新消息开始
+// params: [roomId: string, beforeId: int, limit?: int]
+func (r RoomController) RoomHistory(w *wes.WContext, p *historyParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
 	if !ok {
 		w.Result(dataType.NotFound, "room not found")
 		return
@@ -306,57 +304,90 @@ func (r RoomController) RoomMessage(w *wes.WContext) {
 		w.Result(dataType.DeniedByPermission, "not in room")
 		return
 	}
-	room.Message(message, w.Conn)
-	w.Result(dataType.Success, "success")
+	w.Result(dataType.Success, room.History().Before(p.MessageId, p.Limit))
 }
 
-// ListRoom 所有房间信息接口
-func (r RoomController) ListRoom(c *gin.Context) {
-	type respInfo struct {
-		Total int                  `json:"total"`
-		Rooms []subscribe.RoomInfo `json:"rooms"`
+// RoomSince 获取指定id之后的消息，用于断线重连后续传
+// params: [roomId: string, afterId: int, limit?: int]
+func (r RoomController) RoomSince(w *wes.WContext, p *historyParams) {
+	room, ok := subscribe.Roomer.Get(p.RoomId)
+	if !ok {
+		w.Result(dataType.NotFound, "room not found")
+		return
 	}
-
-	pageNum, err := strconv.Atoi(c.Query("page"))
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusOK, dataType.JsonRes{
-			Code: dataType.WrongBody, Data: "invalided page",
-		})
+	if !room.IsSuber(w.Conn) {
+		w.Result(dataType.DeniedByPermission, "not in room")
 		return
 	}
+	w.Result(dataType.Success, room.History().After(p.MessageId, p.Limit))
+}
 
-	pageSize, err := strconv.Atoi(c.Query("size"))
-	if err != nil {
+// 房间分页查询参数
+type roomListQuery struct {
+	Page int `form:"page" binding:"required,gte=1"`
+	Size int `form:"size" binding:"required,gte=1"`
+}
+
+// 房间分页结果
+type roomList struct {
+	Total int                  `json:"total"`
+	Rooms []subscribe.RoomInfo `json:"rooms"`
+}
+
+// ListRoom 所有房间信息接口
+func (r RoomController) ListRoom(c *gin.Context) {
+	var q roomListQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
 		c.AbortWithStatusJSON(http.StatusOK, dataType.JsonRes{
-			Code: dataType.WrongBody, Data: "invalided page",
+			Code: dataType.WrongBody, Data: err.Error(),
 		})
 		return
 	}
 
 	c.AbortWithStatusJSON(http.StatusOK, dataType.JsonRes{
 		Code: dataType.Success,
-		Data: respInfo{
+		Data: roomList{
 			Total: subscribe.Roomer.Size(),
-			Rooms: subscribe.Roomer.List(pageNum, pageSize),
+			Rooms: subscribe.Roomer.List(q.Page, q.Size),
 		},
 	})
 }
 
 func (r RoomController) RegisterRoute(route string, g *gin.RouterGroup) {
-	g.Group(route).Handle("GET", "list", r.ListRoom)
+	openapi.Handle(g.Group(route), "GET", "list", r.ListRoom).
+		Doc("分页获取所有房间信息").Query(roomListQuery{}).Returns(roomList{})
 
 }
 
 func (r RoomController) RegisterWSRoute(route string, g *wes.Group) {
 	group := g.Group(route)
 	group.Use(middleware.AuthMiddle.WsHandle)
-	group.Register("in", r.GetInRoom)
-	group.Register("out", r.GetOutRoom)
-	group.Register("close", r.CloseRoom)
-	group.Register("forbidden", r.ForbiddenRoom)
-	group.Register("message", r.RoomMessage)
-	group.Register("roommate", r.RoomMate)
-	group.Register("create", r.CreateRoom)
-	group.Register("kick", r.KickMember)
-	group.Register("link", r.Link)
+	group.Register("in", wes.Bind(r.GetInRoom)).
+		Doc("进入房间，返回房间成员").Accepts(inRoomParams{}).Returns([]subscribe.MateInfo{})
+	group.Register("out", wes.Bind(r.GetOutRoom)).
+		Doc("退出房间").Accepts(roomParams{}).Returns("")
+	group.Register("close", wes.Bind(r.CloseRoom)).
+		Doc("关闭房间，仅房主可用").Accepts(roomParams{}).Returns("")
+	group.Register("forbidden", wes.Bind(r.ForbiddenRoom)).
+		Doc("设置房间是否禁止进入，仅房主可用").Accepts(forbiddenParams{}).Returns("")
+	group.Register("message", wes.Bind(r.RoomMessage)).
+		Doc("发送房间消息，开启消息记录时返回消息id，否则返回success").Accepts(roomMessageParams{}).Returns(int64(0))
+	group.Register("history", wes.Bind(r.RoomHistory)).
+		Doc("分页获取历史消息，messageId为0时从最新消息开始").Accepts(historyParams{}).Returns([]subscribe.RoomMessage{})
+	group.Register("since", wes.Bind(r.RoomSince)).
+		Doc("获取指定id之后的消息，用于断线重连后续传").Accepts(historyParams{}).Returns([]subscribe.RoomMessage{})
+	group.Register("roommate", wes.Bind(r.RoomMate)).
+		Doc("获取房间成员").Accepts(roomParams{}).Returns([]subscribe.MateInfo{})
+	group.Register("create", wes.Bind(r.CreateRoom)).
+		Doc("创建房间").Accepts(createRoomParams{}).Returns(createRoomResult{})
+	group.Register("kick", wes.Bind(r.KickMember)).
+		Doc("踢出房间成员，仅房主可用，踢出后仍可再次进入").Accepts(kickParams{}).Returns("")
+	group.Register("ban", wes.Bind(r.BanMember)).
+		Doc("封禁用户，用户在房间内时同时踢出，仅房主可用").Accepts(kickParams{}).Returns("")
+	group.Register("unban", wes.Bind(r.UnbanMember)).
+		Doc("解除封禁，仅房主可用").Accepts(kickParams{}).Returns("")
+	group.Register("bans", wes.Bind(r.Bans)).
+		Doc("封禁名单，仅房主可用").Accepts(roomParams{}).Returns([]string{})
+	group.Register("link", wes.Bind(r.Link)).
+		Doc("获取房间链接").Accepts(roomParams{}).Returns("")
 }
@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"ginWeb/service/dataType"
+	"ginWeb/service/openapi"
+	"ginWeb/service/wes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导出文档的标题
+const apiTitle = "ginWeb websocket api"
+
+type SystemController struct {
+}
+
+// 导出格式参数
+type methodsParams struct {
+	Format string `json:"format" validate:"omitempty,oneof=openrpc asyncapi"`
+}
+
+// 按格式导出ws方法文档，默认为OpenRPC
+func methodsDocument(format string) map[string]interface{} {
+	if format == "asyncapi" {
+		return wes.AsyncAPI(apiTitle)
+	}
+	return wes.OpenRPC(apiTitle)
+}
+
+// Methods 导出所有ws方法的OpenRPC或AsyncAPI文档
+// params: [format?: "openrpc" | "asyncapi"]
+func (s SystemController) Methods(w *wes.WContext, p *methodsParams) {
+	w.Result(dataType.Success, methodsDocument(p.Format))
+}
+
+// HttpMethods 导出所有ws方法的文档，直接返回文档用于生成客户端SDK
+func (s SystemController) HttpMethods(c *gin.Context) {
+	var q methodsQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.AbortWithStatusJSON(http.StatusOK, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, methodsDocument(q.Format))
+}
+
+// 导出格式查询参数
+type methodsQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=openrpc asyncapi"`
+}
+
+func (s SystemController) RegisterRoute(r string, g *gin.RouterGroup) {
+	openapi.Handle(g.Group(r), "GET", "methods", s.HttpMethods).
+		Doc("导出所有ws方法的OpenRPC或AsyncAPI文档，直接返回文档").Query(methodsQuery{}).Returns(map[string]interface{}{}).Raw()
+}
+
+func (s SystemController) RegisterWSRoute(r string, g *wes.Group) {
+	group := g.Group(r)
+	group.Register("methods", wes.Bind(s.Methods)).
+		Doc("导出所有ws方法的OpenRPC或AsyncAPI文档").Accepts(methodsParams{}).Returns(map[string]interface{}{})
+}
@@ -7,6 +7,7 @@ import (
 	"ginWeb/route"
 	"ginWeb/service/scheduler"
 	"ginWeb/service/udp"
+	"ginWeb/service/wes/subscribe"
 	"ginWeb/service/wireguard"
 	"ginWeb/utils/loguru"
 	"log"
@@ -60,6 +61,8 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("start wireguard failed: %s", err.Error()))
 	}
+	// 恢复重启前的房间
+	subscribe.Roomer.Recover()
 	err = udp.UdpSvr.Run()
 	if err != nil {
 		panic(fmt.Sprintf("start udp server failed: %s", err.Error()))
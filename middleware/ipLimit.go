@@ -43,16 +43,16 @@ func (i *ipLimiter) Reset(p PeriodType) {
 	}
 }
 
-func (i *ipLimiter) handle(ip string) (error, bool) {
-	countM, err := reCache.Incr(namespace, i.key(MinuteP, ip))
+func (i *ipLimiter) handle(ctx context.Context, ip string) (error, bool) {
+	countM, err := reCache.IncrCtx(ctx, namespace, i.key(MinuteP, ip))
 	if err != nil {
 		return err, false
 	}
-	countH, err := reCache.Incr(namespace, i.key(HourP, ip))
+	countH, err := reCache.IncrCtx(ctx, namespace, i.key(HourP, ip))
 	if err != nil {
 		return err, false
 	}
-	countD, err := reCache.Incr(namespace, i.key(DayP, ip))
+	countD, err := reCache.IncrCtx(ctx, namespace, i.key(DayP, ip))
 	if err != nil {
 		return err, false
 	}
@@ -63,7 +63,7 @@ func (i *ipLimiter) handle(ip string) (error, bool) {
 }
 
 func (i *ipLimiter) HttpHandle(c *gin.Context) {
-	err, ok := i.handle(c.ClientIP())
+	err, ok := i.handle(c.Request.Context(), c.ClientIP())
 	if err != nil {
 		c.AbortWithStatusJSON(200, dataType.JsonWrong{
 			Code: dataType.Unknown, Message: "failed",
@@ -79,7 +79,7 @@ func (i *ipLimiter) HttpHandle(c *gin.Context) {
 }
 
 func (i *ipLimiter) WsHandle(c *wes.WContext) {
-	err, ok := i.handle(c.Conn.IP)
+	err, ok := i.handle(c.Ctx(), c.Conn.IP)
 	if err != nil {
 		c.Result(dataType.Unknown, "denied")
 		return
@@ -5,6 +5,8 @@ import (
 	"ginWeb/service/wes"
 	"ginWeb/utils/auth"
 	"ginWeb/utils/tools"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -48,8 +50,18 @@ func (p *permission) WsHandle(c *wes.WContext) {
 	}
 }
 
+// Permissions 所需权限的描述，必要权限逐个列出，可选权限以|连接表示满足其一
+func (p *permission) Permissions() []string {
+	list := make([]string, 0, len(p.Permission)+len(p.SelectPermission))
+	list = append(list, p.Permission...)
+	for _, choice := range p.SelectPermission {
+		list = append(list, strings.Join(choice, "|"))
+	}
+	return list
+}
+
 // NewPermission 对比token中存储的权限是否足够，需要前置loginStatus中间件
-func NewPermission(perms []string, choice ...[]string) Middleware {
+func NewPermission(perms []string, choice ...[]string) wes.Guard {
 	per := &permission{
 		Permission:       perms,
 		SelectPermission: choice,
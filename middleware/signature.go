@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"fmt"
+	reCache "ginWeb/service/cache"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/utils/loguru"
+	"time"
+)
+
+type signature struct {
+}
+
+// ws请求签名中间件，校验签名并通过redis拒绝重复的nonce
+func (s *signature) WsHandle(w *wes.WContext) {
+	if err := w.VerifySignature(); err != nil {
+		w.Result(dataType.InvalidSignature, err.Error())
+		return
+	}
+	// nonce保留两倍窗口期，覆盖时间戳允许的前后偏差
+	ex := uint(2 * wes.SignWindow / time.Second)
+	ok, err := reCache.SetNXCtx(w.Ctx(), "wsNonce", w.Conn.Uuid+":"+w.Request.Nonce, 1, ex)
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "SIGNATURE", fmt.Sprintf("check nonce failed: %s", err.Error()))
+		w.Result(dataType.Unknown, "check nonce failed")
+		return
+	}
+	if !ok {
+		w.Result(dataType.ReplayedRequest, "replayed request")
+	}
+}
+
+// SignMiddle ws请求签名中间件实例
+var SignMiddle = &signature{}
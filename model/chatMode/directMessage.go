@@ -0,0 +1,86 @@
+package chatMode
+
+import (
+	"context"
+	"ginWeb/model"
+	"ginWeb/utils/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DirectMessage 用户间私信
+type DirectMessage struct {
+	model.BaseModel `gorm:"embedded"`
+	Uuid            string `gorm:"size:36;NOT NULL;UNIQUE;index"`
+	SenderUuid      string `gorm:"size:36;index;NOT NULL"`
+	SenderName      string `gorm:"size:100;NOT NULL;DEFAULT:''"`
+	TargetUuid      string `gorm:"size:36;index;NOT NULL"`
+	Content         string `gorm:"type:text"`
+	DeliveredAt     int64  `gorm:"DEFAULT:0;index;comment:'0为未送达'"`
+	ReadAt          int64  `gorm:"DEFAULT:0;comment:'0为未读'"`
+}
+
+// Create 保存私信
+func (d *DirectMessage) Create(ctx context.Context) error {
+	return database.WithContext(ctx).Table("direct_message").Create(d).Error
+}
+
+// GetDirectMessage 按uuid获取私信
+func GetDirectMessage(ctx context.Context, messageUuid string) (*DirectMessage, error) {
+	var msg DirectMessage
+	resp := database.WithContext(ctx).Table("direct_message").
+		Where("uuid = ? and deleted = false", messageUuid).First(&msg)
+	return &msg, resp.Error
+}
+
+// UndeliveredOf 用户所有未送达的私信
+func UndeliveredOf(targetUuid string) ([]DirectMessage, error) {
+	var msgs []DirectMessage
+	resp := database.Db.Table("direct_message").
+		Where("target_uuid = ? and delivered_at = 0 and deleted = false", targetUuid).
+		Order("id").Find(&msgs)
+	return msgs, resp.Error
+}
+
+// MarkDelivered 标记发送给targetUuid的私信已送达，返回实际被标记的私信
+func MarkDelivered(ctx context.Context, targetUuid string, uuids ...string) ([]DirectMessage, error) {
+	var msgs []DirectMessage
+	if len(uuids) == 0 {
+		return msgs, nil
+	}
+	tx := database.WithContext(ctx).Begin()
+	defer tx.Commit()
+	resp := tx.Table("direct_message").Where("uuid in ? and target_uuid = ? and delivered_at = 0", uuids, targetUuid).Find(&msgs)
+	if resp.Error != nil || len(msgs) == 0 {
+		return msgs, resp.Error
+	}
+	now := time.Now().UnixMilli()
+	resp = tx.Table("direct_message").Where("uuid in ? and target_uuid = ? and delivered_at = 0", uuids, targetUuid).
+		Update("delivered_at", now)
+	for i := range msgs {
+		msgs[i].DeliveredAt = now
+	}
+	return msgs, resp.Error
+}
+
+// MarkRead 标记发送给targetUuid的私信已读，返回实际被标记的私信
+func MarkRead(ctx context.Context, targetUuid string, uuids ...string) ([]DirectMessage, error) {
+	var msgs []DirectMessage
+	if len(uuids) == 0 {
+		return msgs, nil
+	}
+	tx := database.WithContext(ctx).Begin()
+	defer tx.Commit()
+	resp := tx.Table("direct_message").Where("uuid in ? and target_uuid = ? and read_at = 0", uuids, targetUuid).Find(&msgs)
+	if resp.Error != nil || len(msgs) == 0 {
+		return msgs, resp.Error
+	}
+	now := time.Now().UnixMilli()
+	resp = tx.Table("direct_message").Where("uuid in ? and target_uuid = ? and read_at = 0", uuids, targetUuid).
+		Updates(map[string]any{"read_at": now, "delivered_at": gorm.Expr("IF(delivered_at = 0, ?, delivered_at)", now)})
+	for i := range msgs {
+		msgs[i].ReadAt = now
+	}
+	return msgs, resp.Error
+}
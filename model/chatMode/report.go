@@ -0,0 +1,73 @@
+package chatMode
+
+import (
+	"context"
+	"errors"
+	"ginWeb/model"
+	"ginWeb/utils/database"
+)
+
+const (
+	ReportPending  = 0 // 待处理
+	ReportAccepted = 1 // 举报成立
+	ReportRejected = 2 // 举报驳回
+)
+
+// MessageReport 消息举报
+type MessageReport struct {
+	model.BaseModel `gorm:"embedded"`
+	MessageId       string `gorm:"size:36;index;NOT NULL;comment:'房间消息id、频道消息引用id或私信uuid'"`
+	Scope           string `gorm:"size:64;index;NOT NULL;comment:'room:房间uuid、channel:频道名或dm'"`
+	ReporterUuid    string `gorm:"size:36;index;NOT NULL"`
+	SenderUuid      string `gorm:"size:36;index;NOT NULL"`
+	SenderName      string `gorm:"size:100;NOT NULL;DEFAULT:''"`
+	Content         string `gorm:"type:text"`
+	Reason          string `gorm:"size:255;DEFAULT:''"`
+	Status          int    `gorm:"DEFAULT:0;index;comment:'0: pending, 1: accepted, 2: rejected'"`
+	HandlerUuid     string `gorm:"size:36;DEFAULT:''"`
+}
+
+// Create 保存举报，同一用户对同一消息只能举报一次
+func (r *MessageReport) Create(ctx context.Context) error {
+	db := database.WithContext(ctx)
+	var exist int64
+	resp := db.Table("message_report").
+		Where("scope = ? and message_id = ? and reporter_uuid = ? and deleted = false", r.Scope, r.MessageId, r.ReporterUuid).
+		Count(&exist)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if exist != 0 {
+		return errors.New("already reported")
+	}
+	return db.Table("message_report").Create(r).Error
+}
+
+// ListReports 分页查询举报，status小于0时查询全部
+func ListReports(status int, page int, size int) (reports []MessageReport, total int64, err error) {
+	query := database.Db.Table("message_report").Where("deleted = false")
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return
+	}
+	err = query.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&reports).Error
+	return
+}
+
+// HandleReport 处理举报
+func HandleReport(id int64, status int, handlerUuid string) error {
+	if status != ReportAccepted && status != ReportRejected {
+		return errors.New("invalid status")
+	}
+	resp := database.Db.Table("message_report").Where("id = ? and deleted = false", id).
+		Updates(map[string]any{"status": status, "handler_uuid": handlerUuid})
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.RowsAffected == 0 {
+		return errors.New("report not found")
+	}
+	return nil
+}
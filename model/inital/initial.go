@@ -5,6 +5,8 @@ import (
 	"ginWeb/config"
 	"ginWeb/model"
 	"ginWeb/model/authMode"
+	"ginWeb/model/chatMode"
+	"ginWeb/model/roomMode"
 	"ginWeb/model/systemMode"
 	"ginWeb/utils/auth"
 	"ginWeb/utils/database"
@@ -20,7 +22,9 @@ func InitializeMode() {
 	err := db.Set("gorm:table_options", "charset=utf8mb4").AutoMigrate(&systemMode.User{}, &authMode.Permissions{},
 		&authMode.Group{}, &authMode.UserGroup{}, &authMode.GroupPermission{},
 		&authMode.Role{}, &authMode.UserRole{}, &authMode.RolePermission{},
-		&systemMode.UserBlacklist{},
+		&systemMode.UserBlacklist{}, &systemMode.Friendship{},
+		&roomMode.Room{}, &roomMode.RoomBan{},
+		&chatMode.DirectMessage{}, &chatMode.MessageReport{},
 	)
 	if err != nil {
 		loguru.SimpleLog(loguru.Fatal, "SYSTEM", fmt.Sprintf("create table failed %s", err.Error()))
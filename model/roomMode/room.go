@@ -0,0 +1,84 @@
+package roomMode
+
+import (
+	"errors"
+	"ginWeb/model"
+	"ginWeb/utils/database"
+)
+
+// Room 持久化的房间信息
+type Room struct {
+	model.BaseModel `gorm:"embedded"`
+	Uuid            string `gorm:"size:36;NOT NULL;UNIQUE;index"`
+	Link            string `gorm:"size:36;NOT NULL;index"`
+	OwnerUuid       string `gorm:"size:36;NOT NULL;index"`
+	OwnerId         int64  `gorm:"NOT NULL"`
+	OwnerName       string `gorm:"size:100;NOT NULL;DEFAULT:''"`
+	Config          string `gorm:"type:text;comment:'json格式房间设置'"`
+	Forbidden       bool   `gorm:"DEFAULT:false"`
+}
+
+// RoomBan 房间封禁名单
+type RoomBan struct {
+	model.BaseModel `gorm:"embedded"`
+	RoomUuid        string `gorm:"size:36;index;NOT NULL"`
+	TargetUuid      string `gorm:"size:36;index;NOT NULL"`
+}
+
+// Save 不存在则创建，存在则更新
+func (r Room) Save() error {
+	if r.Uuid == "" {
+		return errors.New("blank uuid")
+	}
+	var exist Room
+	database.Db.Table("room").Where("uuid = ?", r.Uuid).First(&exist)
+	if exist.Id == 0 {
+		return database.Db.Table("room").Create(&r).Error
+	}
+	return database.Db.Table("room").Where("id = ?", exist.Id).Updates(map[string]any{
+		"owner_uuid": r.OwnerUuid,
+		"owner_id":   r.OwnerId,
+		"owner_name": r.OwnerName,
+		"config":     r.Config,
+		"forbidden":  r.Forbidden,
+	}).Error
+}
+
+// CloseRoom 标记删除房间及其封禁名单
+func CloseRoom(roomUuid string) error {
+	tx := database.Db.Begin()
+	defer tx.Commit()
+	resp := tx.Table("room").Where("uuid = ?", roomUuid).Update("deleted", true)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return tx.Table("room_ban").Where("room_uuid = ?", roomUuid).Update("deleted", true).Error
+}
+
+// AliveRooms 所有未关闭的房间
+func AliveRooms() ([]Room, error) {
+	var rooms []Room
+	resp := database.Db.Table("room").Where("deleted = false").Order("id").Find(&rooms)
+	return rooms, resp.Error
+}
+
+// Add 添加封禁
+func (b RoomBan) Add() error {
+	if b.RoomUuid == "" || b.TargetUuid == "" {
+		return errors.New("blank uuid")
+	}
+	return database.Db.Table("room_ban").Create(&b).Error
+}
+
+// Remove 解除封禁
+func (b RoomBan) Remove() error {
+	return database.Db.Table("room_ban").Where("room_uuid = ? and target_uuid = ? and deleted = false", b.RoomUuid, b.TargetUuid).
+		Update("deleted", true).Error
+}
+
+// BansOf 房间的封禁名单
+func BansOf(roomUuid string) ([]string, error) {
+	var targets []string
+	resp := database.Db.Table("room_ban").Where("room_uuid = ? and deleted = false", roomUuid).Pluck("target_uuid", &targets)
+	return targets, resp.Error
+}
@@ -3,12 +3,15 @@ package systemMode
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"errors"
 	"ginWeb/model"
 	reCache "ginWeb/service/cache"
 	"ginWeb/utils/database"
+
+	"github.com/go-redis/redis/v8"
 )
 
 type UserBlacklist struct {
@@ -70,3 +73,64 @@ func ExistInList(userUuid string, targetUuid string) (bool, error) {
 	}
 	return exist, nil
 }
+
+// ExistManyInList 批量判断目标是否在用户的黑名单中，结果与targetUuids一一对应
+func ExistManyInList(userUuid string, targetUuids ...string) ([]bool, error) {
+	result := make([]bool, len(targetUuids))
+	if len(targetUuids) == 0 {
+		return result, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	members := make([]interface{}, len(targetUuids))
+	for i, targetUuid := range targetUuids {
+		members[i] = targetUuid
+	}
+	resp := database.Rdb.SMIsMember(ctx, fmt.Sprintf("::blacklist::%s", userUuid), members...)
+	if resp.Err() == nil {
+		return resp.Val(), nil
+	}
+	// 缓存不可用，查询数据库
+	var targets []string
+	err := database.Db.Table("user_blacklist").Where("user_uuid = ? and target_uuid in ? and deleted = false", userUuid, targetUuids).
+		Pluck("target_uuid", &targets).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, targetUuid := range targetUuids {
+		result[i] = slices.Contains(targets, targetUuid)
+	}
+	return result, nil
+}
+
+// InManyLists 批量判断目标是否在各用户的黑名单中，结果与userUuids一一对应
+func InManyLists(userUuids []string, targetUuid string) ([]bool, error) {
+	result := make([]bool, len(userUuids))
+	if len(userUuids) == 0 {
+		return result, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	pipe := database.Rdb.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(userUuids))
+	for i, userUuid := range userUuids {
+		cmds[i] = pipe.SIsMember(ctx, fmt.Sprintf("::blacklist::%s", userUuid), targetUuid)
+	}
+	if _, err := pipe.Exec(ctx); err == nil {
+		for i, cmd := range cmds {
+			result[i] = cmd.Val()
+		}
+		return result, nil
+	}
+	// 缓存不可用，查询数据库
+	var owners []string
+	err := database.Db.Table("user_blacklist").Where("user_uuid in ? and target_uuid = ? and deleted = false", userUuids, targetUuid).
+		Pluck("user_uuid", &owners).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, userUuid := range userUuids {
+		result[i] = slices.Contains(owners, userUuid)
+	}
+	return result, nil
+}
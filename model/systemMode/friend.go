@@ -0,0 +1,138 @@
+package systemMode
+
+import (
+	"errors"
+	"ginWeb/model"
+	"ginWeb/utils/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	FriendPending  = 0 // 待处理
+	FriendAccepted = 1 // 已成为好友
+	FriendRejected = 2 // 已拒绝
+)
+
+var (
+	ErrFriendExist     = errors.New("already friends")
+	ErrFriendRequested = errors.New("friend request already sent")
+	ErrFriendNotFound  = errors.New("friend request not found")
+)
+
+// Friendship 好友关系，UserUuid为申请者，FriendUuid为被申请者
+type Friendship struct {
+	model.BaseModel `gorm:"embedded"`
+	UserUuid        string `gorm:"size:36;index;NOT NULL"`
+	UserName        string `gorm:"size:100;NOT NULL;DEFAULT:''"`
+	FriendUuid      string `gorm:"size:36;index;NOT NULL"`
+	Status          int    `gorm:"DEFAULT:0;index;comment:'0: pending, 1: accepted, 2: rejected'"`
+	Message         string `gorm:"size:255;DEFAULT:''"`
+}
+
+// 两个用户之间生效中的关系
+func between(tx *gorm.DB, a string, b string) *gorm.DB {
+	return tx.Table("friendship").
+		Where("((user_uuid = ? and friend_uuid = ?) or (user_uuid = ? and friend_uuid = ?)) and deleted = false",
+			a, b, b, a)
+}
+
+// RequestFriend 发送好友申请，对方已向自己申请时直接成为好友，返回值表示是否已成为好友
+func RequestFriend(f *Friendship) (bool, error) {
+	if f.UserUuid == "" || f.FriendUuid == "" || f.UserUuid == f.FriendUuid {
+		return false, errors.New("invalid uuid")
+	}
+	accepted := false
+	err := database.Db.Transaction(func(tx *gorm.DB) error {
+		// 按uuid顺序锁定双方用户记录，双方同时申请时串行执行，后执行的一方直接成为好友
+		var users []User
+		if err := tx.Table("user").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uuid in ?", []string{f.UserUuid, f.FriendUuid}).Order("uuid").Find(&users).Error; err != nil {
+			return err
+		}
+		var exist []Friendship
+		if err := between(tx, f.UserUuid, f.FriendUuid).Where("status != ?", FriendRejected).Find(&exist).Error; err != nil {
+			return err
+		}
+		for _, v := range exist {
+			if v.Status == FriendAccepted {
+				return ErrFriendExist
+			}
+			if v.UserUuid == f.UserUuid {
+				return ErrFriendRequested
+			}
+			// 对方已发送申请
+			accepted = true
+			return tx.Table("friendship").Where("id = ?", v.Id).Update("status", FriendAccepted).Error
+		}
+		f.Status = FriendPending
+		return tx.Table("friendship").Create(f).Error
+	})
+	return accepted, err
+}
+
+// ReplyFriend 处理收到的好友申请，accept为false时拒绝
+func ReplyFriend(userUuid string, requesterUuid string, accept bool) error {
+	status := FriendRejected
+	if accept {
+		status = FriendAccepted
+	}
+	resp := database.Db.Table("friendship").
+		Where("user_uuid = ? and friend_uuid = ? and status = ? and deleted = false", requesterUuid, userUuid, FriendPending).
+		Update("status", status)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.RowsAffected == 0 {
+		return ErrFriendNotFound
+	}
+	return nil
+}
+
+// RemoveFriend 删除好友，标记删除
+func RemoveFriend(userUuid string, friendUuid string) error {
+	resp := between(database.Db, userUuid, friendUuid).Where("status = ?", FriendAccepted).Update("deleted", true)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.RowsAffected == 0 {
+		return ErrFriendNotFound
+	}
+	return nil
+}
+
+// IsFriend 是否为好友
+func IsFriend(userUuid string, friendUuid string) (bool, error) {
+	var c int64
+	err := between(database.Db, userUuid, friendUuid).Where("status = ?", FriendAccepted).Count(&c).Error
+	return c != 0, err
+}
+
+// FriendsOf 用户的好友uuid列表
+func FriendsOf(userUuid string) ([]string, error) {
+	var records []Friendship
+	err := database.Db.Table("friendship").
+		Where("(user_uuid = ? or friend_uuid = ?) and status = ? and deleted = false", userUuid, userUuid, FriendAccepted).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	friends := make([]string, 0, len(records))
+	for _, v := range records {
+		if v.UserUuid == userUuid {
+			friends = append(friends, v.FriendUuid)
+		} else {
+			friends = append(friends, v.UserUuid)
+		}
+	}
+	return friends, nil
+}
+
+// FriendRequestsOf 用户收到的待处理好友申请
+func FriendRequestsOf(userUuid string) (requests []Friendship, err error) {
+	err = database.Db.Table("friendship").
+		Where("friend_uuid = ? and status = ? and deleted = false", userUuid, FriendPending).
+		Order("id desc").Find(&requests).Error
+	return
+}
@@ -48,3 +48,13 @@ func GetUserByUsername(username string) (*User, error) {
 	}
 	return &user, nil
 }
+
+// GetUserByUuid 根据uuid获取用户信息
+func GetUserByUuid(uuid string) (*User, error) {
+	var user User
+	result := database.Db.Table("user").Where("uuid = ?", uuid).First(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
@@ -6,13 +6,18 @@ import (
 	"ginWeb/controller/blacklist"
 	configApi "ginWeb/controller/config"
 	"ginWeb/controller/debug"
+	"ginWeb/controller/docs"
+	"ginWeb/controller/friend"
+	"ginWeb/controller/moderation"
 	"ginWeb/controller/perm"
 	"ginWeb/controller/server"
 	"ginWeb/controller/user"
 	"ginWeb/controller/ws"
 	"ginWeb/middleware"
+	"ginWeb/service/openapi"
 	"ginWeb/service/wes"
 	"ginWeb/service/wes/subscribe"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,17 +27,19 @@ func InitRoute(g *gin.Engine) {
 
 	// 开放api组
 	api := g.Group("/api")
-	api.Handle("POST", "/register", middleware.NewIpLimiter(10, 0, 0, "/register").HttpHandle, user.PublicRegister)
+	user.Register{}.RegisterRoute("/register", api)
 	auth.Login{}.RegisterRoute("/login", api)
 	server.Server{}.RegisterRoute("/server", api)
 
 	// 带token验证的api组
 	sapi := g.Group("/sapi")
 	sapi.Use(middleware.AuthMiddle.HttpHandle)
+	openapi.Secure(sapi)
 	configApi.Admin{}.RegisterRoute("/config", sapi)
 	auth.Logout{}.RegisterRoute("/logout", sapi)
 	auth.FreshToken{}.RegisterRoute("/freshToken", sapi)
 	blacklist.UserList{}.RegisterRoute("/blacklist", sapi)
+	friend.Friend{}.RegisterRoute("/friend", sapi)
 	server.InfoMessage{}.RegisterRoute("/info", sapi)
 
 	// 系统api组
@@ -40,6 +47,11 @@ func InitRoute(g *gin.Engine) {
 	perm.Grant{}.RegisterRoute("/grant", systemApi)
 	user.Users{}.RegisterRoute("/user", systemApi)
 	perm.Permission{}.RegisterRoute("/permission", systemApi)
+	moderation.Report{}.RegisterRoute("/report", systemApi)
+
+	if config.Conf.Server.ApiDoc {
+		docs.OpenApi{}.RegisterRoute("/docs", api)
+	}
 
 	if config.Conf.Server.Debug {
 		debugGroup := g.Group("/debug")
@@ -47,6 +59,14 @@ func InitRoute(g *gin.Engine) {
 	}
 }
 
+// 按配置返回ws处理组的父组，需要签名的组添加签名中间件
+func wsGroup(name string) *wes.Group {
+	if slices.Contains(config.Conf.Server.Websocket.Signature.Groups, name) {
+		return wes.BasicGroup.Group("", middleware.SignMiddle.WsHandle)
+	}
+	return wes.BasicGroup
+}
+
 // InitWs 注册ws处理逻辑
 func InitWs(g *gin.Engine) {
 	defer wes.PrintTasks()
@@ -54,20 +74,46 @@ func InitWs(g *gin.Engine) {
 	g.Handle("GET", "/ws",
 		middleware.NewIpLimiter(10, 0, 0, "ws").HttpHandle,
 		wes.UpgradeConn)
+	// 无法建立websocket时的SSE推送和HTTP请求提交
+	g.Handle("GET", "/sse",
+		middleware.NewIpLimiter(10, 0, 0, "sse").HttpHandle,
+		wes.SSEConn)
+	// 每次调用为一个请求，限额高于建立连接
+	g.Handle("POST", "/sse/call",
+		middleware.NewIpLimiter(600, 0, 0, "sseCall").HttpHandle,
+		wes.SSECall)
 	// 用于ws提供的某些http接口
 	wsApi := g.Group("/ws")
 	wsApi.Use(middleware.AuthMiddle.HttpHandle)
+	openapi.Secure(wsApi)
 
 	base := ws.Base{}
-	base.RegisterWSRoute("base", wes.BasicGroup)
+	base.RegisterWSRoute("base", wsGroup("base"))
 
 	channel := ws.ChannelController{}
-	channel.RegisterWSRoute("channel", wes.BasicGroup)
+	channel.RegisterWSRoute("channel", wsGroup("channel"))
+	channel.RegisterRoute("channel", wsApi)
 
 	room := ws.RoomController{}
-	room.RegisterWSRoute("room", wes.BasicGroup)
+	room.RegisterWSRoute("room", wsGroup("room"))
 	room.RegisterRoute("room", wsApi)
 
+	dm := ws.DirectController{}
+	dm.RegisterWSRoute("dm", wsGroup("dm"))
+
+	presence := ws.PresenceController{}
+	presence.RegisterWSRoute("presence", wsGroup("presence"))
+
+	friends := ws.FriendController{}
+	friends.RegisterWSRoute("friend", wsGroup("friend"))
+
+	moderator := ws.ModerationController{}
+	moderator.RegisterWSRoute("moderation", wsGroup("moderation"))
+
+	system := ws.SystemController{}
+	system.RegisterWSRoute("system", wsGroup("system"))
+	system.RegisterRoute("system", wsApi)
+
 	// subscribe.Publishers.NewPublisher("time", "*/10 * * * * *", func() string {
 	// 	return time.Now().Format("2006-01-02 15:04:05.000")
 	// })
@@ -23,10 +23,15 @@ func formatter(namespace string, key string) string {
 
 // Set 自动添加前缀
 func Set(namespace string, key string, value any, ex uint) error {
+	return SetCtx(context.Background(), namespace, key, value, ex)
+}
+
+// SetCtx 使用请求上下文设置缓存
+func SetCtx(parent context.Context, namespace string, key string, value any, ex uint) error {
 	if ex == 0 {
 		ex = defaultExpire
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	ctx, cancel := database.RedisContext(parent)
 	defer cancel()
 	resp := database.Rdb.SetEX(ctx, formatter(namespace, key), value, time.Duration(ex)*time.Second)
 	if resp.Err() != nil {
@@ -35,8 +40,8 @@ func Set(namespace string, key string, value any, ex uint) error {
 	return nil
 }
 
-func regetCache(key string, f func() (any, error)) (any, error) {
-	ctx, c := context.WithTimeout(context.Background(), 100*time.Microsecond)
+func regetCache(parent context.Context, key string, f func() (any, error)) (any, error) {
+	ctx, c := database.RedisContext(parent)
 	defer c()
 	resp := database.Rdb.SetNX(ctx, key+"::_lock", true, time.Duration(defaultLockExpire)*time.Second)
 	if resp.Err() != nil {
@@ -49,7 +54,7 @@ func regetCache(key string, f func() (any, error)) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		resetCtx, rec := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		resetCtx, rec := database.RedisContext(parent)
 		defer rec()
 		resp := database.Rdb.Set(resetCtx, key, newData, time.Duration(defaultExpire)*time.Second)
 		if resp.Err() == nil {
@@ -62,12 +67,17 @@ func regetCache(key string, f func() (any, error)) (any, error) {
 
 // 获取缓存数据，不存在则会重新加载
 func Get(namespace string, key string, reget func() (any, error)) (any, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	return GetCtx(context.Background(), namespace, key, reget)
+}
+
+// GetCtx 使用请求上下文获取缓存数据
+func GetCtx(parent context.Context, namespace string, key string, reget func() (any, error)) (any, error) {
+	ctx, cancel := database.RedisContext(parent)
 	defer cancel()
 	name := formatter(namespace, key)
 	resp := database.Rdb.Get(ctx, name)
 	if resp.Err() == redis.Nil && reget != nil {
-		return regetCache(name, reget)
+		return regetCache(parent, name, reget)
 	}
 	if resp.Err() != nil {
 		return nil, resp.Err()
@@ -75,8 +85,27 @@ func Get(namespace string, key string, reget func() (any, error)) (any, error) {
 	return resp.Val(), nil
 }
 
+// SetNXCtx 缓存不存在时设置，已存在返回false
+func SetNXCtx(parent context.Context, namespace string, key string, value any, ex uint) (bool, error) {
+	if ex == 0 {
+		ex = defaultExpire
+	}
+	ctx, cancel := database.RedisContext(parent)
+	defer cancel()
+	resp := database.Rdb.SetNX(ctx, formatter(namespace, key), value, time.Duration(ex)*time.Second)
+	if resp.Err() != nil {
+		return false, resp.Err()
+	}
+	return resp.Val(), nil
+}
+
 func Del(namespace string, key string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	return DelCtx(context.Background(), namespace, key)
+}
+
+// DelCtx 使用请求上下文删除缓存
+func DelCtx(parent context.Context, namespace string, key string) error {
+	ctx, cancel := database.RedisContext(parent)
 	defer cancel()
 	resp := database.Rdb.Del(ctx, formatter(namespace, key))
 	if resp.Err() != nil {
@@ -86,7 +115,12 @@ func Del(namespace string, key string) error {
 }
 
 func Incr(namespace string, key string) (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	return IncrCtx(context.Background(), namespace, key)
+}
+
+// IncrCtx 使用请求上下文自增
+func IncrCtx(parent context.Context, namespace string, key string) (int64, error) {
+	ctx, cancel := database.RedisContext(parent)
 	defer cancel()
 	resp := database.Rdb.Incr(ctx, formatter(namespace, key))
 	if resp.Err() != nil {
@@ -96,7 +130,12 @@ func Incr(namespace string, key string) (int64, error) {
 }
 
 func Decr(namespace string, key string) (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	return DecrCtx(context.Background(), namespace, key)
+}
+
+// DecrCtx 使用请求上下文自减
+func DecrCtx(parent context.Context, namespace string, key string) (int64, error) {
+	ctx, cancel := database.RedisContext(parent)
 	defer cancel()
 	resp := database.Rdb.Decr(ctx, formatter(namespace, key))
 	if resp.Err() != nil {
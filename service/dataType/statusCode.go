@@ -23,8 +23,17 @@ const (
 	IpLimited          = 10105
 	RouteLimited       = 10106
 	UserLimited        = 10107
+	// InvalidSignature 请求签名错误或已过期
+	InvalidSignature = 10108
+	// ReplayedRequest 重复的请求
+	ReplayedRequest = 10109
 
 	WsResolveFailed = 10201
 	WsDuplicateAuth = 10202
 	WsAuthExpire    = 10203
+	// WsCanceled 请求被客户端取消
+	WsCanceled = 10204
+
+	// MessageRejected 消息未通过审核
+	MessageRejected = 10301
 )
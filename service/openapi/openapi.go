@@ -0,0 +1,244 @@
+package openapi
+
+import (
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/utils/tools"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导出文档的接口版本
+const apiVersion = "1.0.0"
+
+var (
+	// 已声明的http接口，按注册顺序
+	operations = make([]*Operation, 0)
+	// 需要token的路由组
+	securedGroups = make([]string, 0)
+	// 添加了权限中间件的路由组及所需权限
+	guardedGroups = make(map[*gin.RouterGroup][]string)
+	// gin路由中的路径参数 :name 和 *name
+	pathParam = regexp.MustCompile(`[:*]([^/]+)`)
+	// 文档中的路径参数 {name}
+	pathVar = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+// Operation http接口描述，注册路由时创建，通过链式调用补充
+type Operation struct {
+	method      string
+	path        string
+	tag         string
+	summary     string
+	query       reflect.Type // 查询参数结构体，按form标签命名
+	body        reflect.Type
+	result      reflect.Type // 成功时JsonRes中data的类型
+	raw         bool         // 成功时直接返回数据，不使用JsonRes包装
+	permissions []string
+}
+
+// Handle 在路由组上注册处理函数，返回接口描述用于补充文档
+func Handle(g *gin.RouterGroup, method string, relativePath string, handlers ...gin.HandlerFunc) *Operation {
+	g.Handle(method, relativePath, handlers...)
+	op := &Operation{
+		method:      strings.ToLower(method),
+		path:        pathParam.ReplaceAllString(joinPath(g.BasePath(), relativePath), "{$1}"),
+		tag:         g.BasePath(),
+		permissions: guardedGroups[g],
+	}
+	operations = append(operations, op)
+	return op
+}
+
+// Secure 标记路由组需要token，在组上添加登录验证中间件时调用
+func Secure(g *gin.RouterGroup) {
+	securedGroups = append(securedGroups, g.BasePath())
+}
+
+// Guard 创建添加了权限中间件的子组，组内直接注册的接口将其所需权限记入文档
+func Guard(g *gin.RouterGroup, guard wes.Guard) *gin.RouterGroup {
+	child := g.Group("")
+	child.Use(guard.HttpHandle)
+	guardedGroups[child] = append(append([]string{}, guardedGroups[g]...), guard.Permissions()...)
+	return child
+}
+
+// Doc 接口说明
+func (o *Operation) Doc(summary string) *Operation {
+	o.summary = summary
+	return o
+}
+
+// Query 查询参数结构体
+func (o *Operation) Query(query interface{}) *Operation {
+	o.query = reflect.TypeOf(query)
+	return o
+}
+
+// Body json请求体类型
+func (o *Operation) Body(body interface{}) *Operation {
+	o.body = reflect.TypeOf(body)
+	return o
+}
+
+// Returns 成功时返回的数据类型
+func (o *Operation) Returns(result interface{}) *Operation {
+	o.result = reflect.TypeOf(result)
+	return o
+}
+
+// Raw 成功时直接返回数据，不使用JsonRes包装
+func (o *Operation) Raw() *Operation {
+	o.raw = true
+	return o
+}
+
+// 拼接路由组路径，与gin一致保留末尾的/
+func joinPath(base string, relative string) string {
+	if relative == "" {
+		return base
+	}
+	p := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// 接口是否需要token
+func (o *Operation) secured() bool {
+	for _, g := range securedGroups {
+		if o.path == g || strings.HasPrefix(o.path, strings.TrimSuffix(g, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 由方法和路径生成operationId，如 GET /sapi/friend/list -> getSapiFriendList
+func (o *Operation) operationId() string {
+	var b strings.Builder
+	b.WriteString(o.method)
+	for _, part := range strings.FieldsFunc(o.path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// 路径参数和查询参数
+func (o *Operation) parameters() []map[string]interface{} {
+	list := make([]map[string]interface{}, 0)
+	for _, match := range pathVar.FindAllStringSubmatch(o.path, -1) {
+		list = append(list, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	if o.query == nil {
+		return list
+	}
+	t := o.query
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, f := range tools.SchemaFields(t) {
+		name := f.Name
+		form, options, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if form == "-" {
+			continue
+		} else if form != "" {
+			name = form
+		}
+		s := tools.Schema(f.Type)
+		// gin绑定时使用的默认值
+		if _, value, ok := strings.Cut(options, "default="); ok {
+			s["default"] = value
+		}
+		param := map[string]interface{}{"name": name, "in": "query", "schema": s}
+		if f.Constrain(s) {
+			param["required"] = true
+		}
+		list = append(list, param)
+	}
+	return list
+}
+
+// 接口的OpenAPI描述，业务错误同样以200返回JsonWrong
+func (o *Operation) spec() map[string]interface{} {
+	success := tools.Schema(o.result)
+	if !o.raw {
+		success = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"code": map[string]interface{}{"type": "integer", "const": dataType.Success},
+				"data": success,
+			},
+			"required": []string{"code"},
+		}
+	}
+	spec := map[string]interface{}{
+		"operationId": o.operationId(),
+		"summary":     o.summary,
+		"tags":        []string{o.tag},
+		"parameters":  o.parameters(),
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "code为0时返回data，否则返回错误信息message",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"oneOf": []interface{}{success, map[string]interface{}{"$ref": "#/components/schemas/JsonWrong"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	if o.body != nil {
+		spec["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": tools.Schema(o.body)},
+			},
+		}
+	}
+	if o.secured() {
+		spec["security"] = []map[string][]string{{"token": {}}}
+	}
+	if len(o.permissions) > 0 {
+		spec["x-permissions"] = o.permissions
+	}
+	return spec
+}
+
+// Document 导出所有已声明http接口的OpenAPI文档
+func Document(title string) map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	for _, op := range operations {
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]interface{})
+		}
+		paths[op.path][op.method] = op.spec()
+	}
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info":    map[string]interface{}{"title": title, "version": apiVersion},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"JsonWrong": tools.Schema(reflect.TypeOf(dataType.JsonWrong{})),
+			},
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{"type": "apiKey", "in": "header", "name": "Token"},
+			},
+		},
+	}
+}
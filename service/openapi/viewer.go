@@ -0,0 +1,47 @@
+package openapi
+
+import (
+	"html/template"
+	"strings"
+)
+
+// ViewerAssets swagger-ui静态资源，Base需固定到具体版本，校验值为子资源完整性(SRI)哈希
+type ViewerAssets struct {
+	Base         string // 如 https://unpkg.com/swagger-ui-dist@5.17.14
+	CssIntegrity string // swagger-ui.css的哈希，如 sha384-...
+	JsIntegrity  string // swagger-ui-bundle.js的哈希
+}
+
+// Verified 是否配置了资源地址和完整性校验
+func (a ViewerAssets) Verified() bool {
+	return a.Base != "" && a.CssIntegrity != "" && a.JsIntegrity != ""
+}
+
+// 在线调试页面，使用swagger-ui加载文档
+var viewer = template.Must(template.New("viewer").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{.Assets.Base}}/swagger-ui.css" integrity="{{.Assets.CssIntegrity}}" crossorigin="anonymous">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets.Base}}/swagger-ui-bundle.js" integrity="{{.Assets.JsIntegrity}}" crossorigin="anonymous"></script>
+<script>
+  window.ui = SwaggerUIBundle({url: {{.Spec}}, dom_id: "#swagger-ui", persistAuthorization: true});
+</script>
+</body>
+</html>
+`))
+
+// Viewer 加载指定文档地址的在线调试页面
+func Viewer(title string, specUrl string, assets ViewerAssets) string {
+	var b strings.Builder
+	assets.Base = strings.TrimSuffix(assets.Base, "/")
+	_ = viewer.Execute(&b, struct {
+		Title, Spec string
+		Assets      ViewerAssets
+	}{title, specUrl, assets})
+	return b.String()
+}
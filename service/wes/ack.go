@@ -0,0 +1,158 @@
+package wes
+
+import (
+	"errors"
+	"fmt"
+	"ginWeb/config"
+	"ginWeb/utils/loguru"
+	"sort"
+	"time"
+)
+
+// 未确认的重要推送重试间隔
+var ackRetryInterval = time.Duration(config.Conf.Server.Websocket.AckRetry) * time.Second
+
+// 重要推送最大重试次数，超出后断开连接由客户端重新同步状态
+var ackMaxRetry = config.Conf.Server.Websocket.AckMaxRetry
+
+// 单个连接最多保留的未确认重要推送，超出后断开连接，与是否重试无关
+var ackMaxPending = func() int {
+	if size := config.Conf.Server.Websocket.AckMaxPending; size > 0 {
+		return size
+	}
+	return 1024
+}()
+
+var ErrTooManyPending = errors.New("too many unacknowledged pushes")
+
+// 待发送的消息，seq为0表示无序号
+type outbound struct {
+	seq  uint64
+	data []byte
+}
+
+// 等待客户端确认的重要推送
+type pendingPush struct {
+	data     []byte
+	sentAt   time.Time
+	attempts int
+}
+
+// Push 推送消息，连接开启确认时分配递增序号，critical为true时在客户端确认前重试，并在恢复会话时重放
+func (c *Connection) Push(r Resp, critical bool) error {
+	if !c.Ack {
+		return c.Send(c.encodePush(r))
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.seq++
+	r.Seq = c.seq
+	data := c.encodePush(r)
+	if critical {
+		if len(c.pending) >= ackMaxPending {
+			loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("%d critical pushes not acknowledged by %s, disconnect", len(c.pending), c.IP))
+			// 断开连接需获取锁，在独立协程中执行
+			go c.Disconnect()
+			return ErrTooManyPending
+		}
+		c.pending[r.Seq] = &pendingPush{data: data, sentAt: time.Now()}
+		// 暂存期间的重要推送在恢复时从pending重放
+		if c.parked {
+			return nil
+		}
+	}
+	if c.parked {
+		return c.buffer(outbound{seq: r.Seq, data: data})
+	}
+	return c.enqueue(data)
+}
+
+// Acknowledge 确认已处理seq及之前的所有推送，返回仍未确认的重要推送数量
+func (c *Connection) Acknowledge(seq uint64) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for s := range c.pending {
+		if s <= seq {
+			delete(c.pending, s)
+		}
+	}
+	return len(c.pending)
+}
+
+// 按序号排序的未确认推送，需在锁内调用
+func (c *Connection) pendingSeqs() []uint64 {
+	seqs := make([]uint64, 0, len(c.pending))
+	for s := range c.pending {
+		seqs = append(seqs, s)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+// 合并未确认的重要推送和暂存期间缓存的消息，按序号顺序返回，需在锁内调用
+func (c *Connection) replayList() [][]byte {
+	seqs := c.pendingSeqs()
+	list := make([][]byte, 0, len(seqs)+len(c.missed))
+	now := time.Now()
+	idx := 0
+	emitBefore := func(seq uint64) {
+		for ; idx < len(seqs) && seqs[idx] < seq; idx++ {
+			p := c.pending[seqs[idx]]
+			p.sentAt = now
+			list = append(list, p.data)
+		}
+	}
+	for _, out := range c.missed {
+		if out.seq != 0 {
+			emitBefore(out.seq)
+		}
+		list = append(list, out.data)
+	}
+	emitBefore(c.seq + 1)
+	return list
+}
+
+// 定时重发超时未确认的重要推送
+func (c *Connection) retryPending() {
+	ticker := time.NewTicker(ackRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.lifetimeCtx.Done():
+			return
+		case <-ticker.C:
+			if c.resendPending() {
+				continue
+			}
+			loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("critical push not acknowledged by %s after %d retries", c.IP, ackMaxRetry))
+			c.Disconnect()
+			return
+		}
+	}
+}
+
+// 重发超时的推送，超出最大重试次数返回false
+func (c *Connection) resendPending() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.parked {
+		return true
+	}
+	now := time.Now()
+	for _, s := range c.pendingSeqs() {
+		p := c.pending[s]
+		if now.Sub(p.sentAt) < ackRetryInterval {
+			continue
+		}
+		if p.attempts >= ackMaxRetry {
+			return false
+		}
+		p.attempts++
+		p.sentAt = now
+		if err := c.enqueue(p.data); err != nil {
+			loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("resend push %d to %s failed: %s", s, c.IP, err.Error()))
+			break
+		}
+	}
+	return true
+}
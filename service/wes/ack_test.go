@@ -0,0 +1,152 @@
+package wes
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// 不带底层连接的测试连接，发送的消息留在发送队列中
+func newTestConn() *Connection {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Connection{
+		lifetimeCtx: ctx,
+		cancel:      cancel,
+		queueSignal: make(chan struct{}, 1),
+		pending:     make(map[uint64]*pendingPush),
+		inflight:    make(map[string]*WContext),
+		doneHooks:   make(map[string]func()),
+		codec:       jsonCodec{},
+	}
+}
+
+// 推送报文中的序号
+func pushSeqs(t *testing.T, list [][]byte) []uint64 {
+	t.Helper()
+	seqs := make([]uint64, 0, len(list))
+	for _, data := range list {
+		var r Resp
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatalf("decode push %s: %v", data, err)
+		}
+		seqs = append(seqs, r.Seq)
+	}
+	return seqs
+}
+
+func TestReplayListOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending []uint64
+		missed  []outbound
+		seq     uint64
+		want    []string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name:    "pending only",
+			pending: []uint64{3, 1, 2},
+			seq:     3,
+			want:    []string{"p1", "p2", "p3"},
+		},
+		{
+			name:   "missed only keeps order",
+			missed: []outbound{{seq: 0, data: []byte("reply")}, {seq: 1, data: []byte("m1")}},
+			seq:    1,
+			want:   []string{"reply", "m1"},
+		},
+		{
+			name:    "merged by seq",
+			pending: []uint64{1, 3, 5},
+			missed: []outbound{
+				{seq: 2, data: []byte("m2")},
+				{seq: 0, data: []byte("reply")},
+				{seq: 4, data: []byte("m4")},
+			},
+			seq:  5,
+			want: []string{"p1", "m2", "reply", "p3", "m4", "p5"},
+		},
+		{
+			name:    "pending after last missed",
+			pending: []uint64{4, 6},
+			missed:  []outbound{{seq: 5, data: []byte("m5")}},
+			seq:     6,
+			want:    []string{"p4", "m5", "p6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConn()
+			for _, s := range tt.pending {
+				c.pending[s] = &pendingPush{data: []byte("p" + string(rune('0'+s)))}
+			}
+			c.missed = tt.missed
+			c.seq = tt.seq
+			got := make([]string, 0)
+			for _, data := range c.replayList() {
+				got = append(got, string(data))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("replayList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushWhileParked(t *testing.T) {
+	old := resumeBuffer
+	resumeBuffer = 16
+	defer func() { resumeBuffer = old }()
+
+	c := newTestConn()
+	c.Ack = true
+	c.parked = true
+	critical := []bool{true, false, true, false}
+	for _, crit := range critical {
+		if err := c.Push(Resp{Method: "publish.test"}, crit); err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+	}
+	// 重要推送只保存在pending中，不重复缓存
+	if got := len(c.missed); got != 2 {
+		t.Fatalf("missed = %d, want 2", got)
+	}
+	if got := c.pendingSeqs(); !slices.Equal(got, []uint64{1, 3}) {
+		t.Fatalf("pending = %v, want [1 3]", got)
+	}
+	if got := pushSeqs(t, c.replayList()); !slices.Equal(got, []uint64{1, 2, 3, 4}) {
+		t.Fatalf("replay seqs = %v, want [1 2 3 4]", got)
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending []uint64
+		ack     uint64
+		left    []uint64
+	}{
+		{"none acknowledged", []uint64{2, 3}, 1, []uint64{2, 3}},
+		{"acknowledges up to seq", []uint64{1, 2, 3}, 2, []uint64{3}},
+		{"acknowledges gaps", []uint64{1, 4, 7}, 5, []uint64{7}},
+		{"acknowledges all", []uint64{1, 2}, 9, []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConn()
+			for _, s := range tt.pending {
+				c.pending[s] = &pendingPush{}
+			}
+			if n := c.Acknowledge(tt.ack); n != len(tt.left) {
+				t.Fatalf("Acknowledge() = %d, want %d", n, len(tt.left))
+			}
+			if got := c.pendingSeqs(); !slices.Equal(got, tt.left) {
+				t.Fatalf("pending = %v, want %v", got, tt.left)
+			}
+		})
+	}
+}
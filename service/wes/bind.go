@@ -0,0 +1,129 @@
+package wes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ginWeb/service/dataType"
+	"ginWeb/utils/tools"
+	"reflect"
+	"strings"
+)
+
+var ErrTooManyParams = errors.New("too many params")
+
+// UnmarshalJSON params支持按位置传入的数组和按名称传入的对象，JSON-RPC请求的id可为字符串或数字
+func (p *payload) UnmarshalJSON(data []byte) error {
+	type alias payload
+	var raw struct {
+		alias
+		Id     json.RawMessage `json:"id"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = payload(raw.alias)
+	id := bytes.TrimSpace(raw.Id)
+	switch {
+	case p.isRpc():
+		// 不带id的JSON-RPC请求为通知，不返回结果
+		p.rpcId = id
+		p.notify = len(id) == 0
+		p.Id = rawId(id)
+	case len(id) > 0 && !bytes.Equal(id, []byte("null")):
+		if err := json.Unmarshal(id, &p.Id); err != nil {
+			return err
+		}
+	}
+	p.rawParams = raw.Params
+	params := bytes.TrimSpace(raw.Params)
+	switch {
+	case len(params) == 0 || bytes.Equal(params, []byte("null")):
+	case params[0] == '{':
+		p.Named = params
+	default:
+		return json.Unmarshal(params, &p.Params)
+	}
+	return nil
+}
+
+// Bind 将请求参数解析到结构体并按validate标签校验
+// 按位置传入时依次对应结构体的导出字段，validate标签含omitempty的字段可省略，其余缺少时返回错误
+// 标记wes:"variadic"的切片字段接收剩余的全部参数，需为最后一个字段
+// 按名称传入时按json标签对应，缺少的参数保持零值
+func (w *WContext) Bind(target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind target must be a struct pointer, got %T", target)
+	}
+	if w.Request.Named != nil {
+		if err := json.Unmarshal(w.Request.Named, target); err != nil {
+			return err
+		}
+		return tools.Validate(target)
+	}
+	elem := v.Elem()
+	idx := 0
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Type().Field(i)
+		name, ok := tools.JsonName(field)
+		if !ok {
+			continue
+		}
+		if variadic(field) {
+			// 剩余参数依次追加到切片
+			slice := elem.Field(i)
+			for ; idx < len(w.Request.Params); idx++ {
+				item := reflect.New(field.Type.Elem())
+				if err := json.Unmarshal(w.Request.Params[idx], item.Interface()); err != nil {
+					return fmt.Errorf("invalid param %s: %w", name, err)
+				}
+				slice.Set(reflect.Append(slice, item.Elem()))
+			}
+			break
+		}
+		if idx >= len(w.Request.Params) {
+			if !optional(field) {
+				return fmt.Errorf("missing param %s", name)
+			}
+			continue
+		}
+		if err := json.Unmarshal(w.Request.Params[idx], elem.Field(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid param %s: %w", name, err)
+		}
+		idx++
+	}
+	if idx < len(w.Request.Params) {
+		return ErrTooManyParams
+	}
+	return tools.Validate(target)
+}
+
+// 按位置传入时可省略的参数
+func optional(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if rule == "omitempty" {
+			return true
+		}
+	}
+	return false
+}
+
+// 按位置传入时接收剩余全部参数的切片字段
+func variadic(field reflect.StructField) bool {
+	return field.Tag.Get("wes") == "variadic" && field.Type.Kind() == reflect.Slice
+}
+
+// Bind 包装带参数结构体的处理函数，参数解析或校验失败时返回WrongBody
+func Bind[T any](f func(w *WContext, params *T)) handleFunc {
+	return func(w *WContext) {
+		var params T
+		if err := w.Bind(&params); err != nil {
+			w.Result(dataType.WrongBody, err.Error())
+			return
+		}
+		f(w, &params)
+	}
+}
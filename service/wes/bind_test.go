@@ -0,0 +1,112 @@
+package wes
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type bindParams struct {
+	Name  string `json:"name" validate:"required"`
+	Count int    `json:"count" validate:"omitempty,gte=0"`
+}
+
+type variadicParams struct {
+	Room string   `json:"room" validate:"required"`
+	Ids  []string `json:"ids" validate:"required,min=1,dive,required" wes:"variadic"`
+}
+
+// 按报文中的params创建请求上下文，params为空时不传参数
+func bindContext(t *testing.T, params string) *WContext {
+	t.Helper()
+	msg := `{"id":"1","method":"test"}`
+	if params != "" {
+		msg = `{"id":"1","method":"test","params":` + params + `}`
+	}
+	var p payload
+	if err := json.Unmarshal([]byte(msg), &p); err != nil {
+		t.Fatalf("decode %s: %v", msg, err)
+	}
+	return &WContext{Request: &p}
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		want   bindParams
+		err    bool
+	}{
+		{name: "positional", params: `["a", 2]`, want: bindParams{Name: "a", Count: 2}},
+		{name: "positional omits optional", params: `["a"]`, want: bindParams{Name: "a"}},
+		{name: "positional missing required", params: `[]`, err: true},
+		{name: "no params", params: "", err: true},
+		{name: "null params", params: `null`, err: true},
+		{name: "positional wrong type", params: `[1]`, err: true},
+		{name: "positional fails validation", params: `["a", -1]`, err: true},
+		{name: "positional empty required", params: `[""]`, err: true},
+		{name: "named", params: `{"name": "a", "count": 3}`, want: bindParams{Name: "a", Count: 3}},
+		{name: "named omits optional", params: `{"name": "a"}`, want: bindParams{Name: "a"}},
+		{name: "named missing required", params: `{"count": 3}`, err: true},
+		{name: "named wrong type", params: `{"name": 1}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bindParams
+			err := bindContext(t, tt.params).Bind(&got)
+			if (err != nil) != tt.err {
+				t.Fatalf("Bind() err = %v, want error %v", err, tt.err)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("Bind() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindTooManyParams(t *testing.T) {
+	var got bindParams
+	if err := bindContext(t, `["a", 1, 2]`).Bind(&got); !errors.Is(err, ErrTooManyParams) {
+		t.Fatalf("Bind() err = %v, want %v", err, ErrTooManyParams)
+	}
+}
+
+func TestBindVariadic(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		want   variadicParams
+		err    bool
+	}{
+		{name: "one item", params: `["r", "x"]`, want: variadicParams{Room: "r", Ids: []string{"x"}}},
+		{name: "many items", params: `["r", "x", "y", "z"]`, want: variadicParams{Room: "r", Ids: []string{"x", "y", "z"}}},
+		{name: "no items", params: `["r"]`, err: true},
+		{name: "empty item", params: `["r", "x", ""]`, err: true},
+		{name: "wrong item type", params: `["r", 1]`, err: true},
+		{name: "named", params: `{"room": "r", "ids": ["x", "y"]}`, want: variadicParams{Room: "r", Ids: []string{"x", "y"}}},
+		{name: "named empty list", params: `{"room": "r", "ids": []}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got variadicParams
+			err := bindContext(t, tt.params).Bind(&got)
+			if (err != nil) != tt.err {
+				t.Fatalf("Bind() err = %v, want error %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Bind() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindTarget(t *testing.T) {
+	var notStruct string
+	if err := bindContext(t, `["a"]`).Bind(&notStruct); err == nil {
+		t.Fatal("Bind() accepted a non-struct target")
+	}
+	if err := bindContext(t, `["a"]`).Bind(bindParams{}); err == nil {
+		t.Fatal("Bind() accepted a non-pointer target")
+	}
+}
@@ -0,0 +1,193 @@
+package wes
+
+import (
+	"ginWeb/utils/tools"
+	"reflect"
+	"sort"
+)
+
+// 导出文档的接口版本
+const apiVersion = "1.0.0"
+
+// 已注册ws方法的描述
+var catalog = make(map[string]*MethodInfo)
+
+// MethodInfo ws方法描述，注册处理函数时创建，通过链式调用补充
+type MethodInfo struct {
+	name        string
+	description string
+	params      reflect.Type // 参数结构体，按位置传入时依次对应导出字段
+	variadic    string       // 可变参数名称，设置时params为单个参数的类型
+	result      reflect.Type
+	permissions []string
+	streaming   bool
+}
+
+// Doc 方法说明
+func (m *MethodInfo) Doc(description string) *MethodInfo {
+	m.description = description
+	return m
+}
+
+// Accepts 参数结构体，与Bind使用的结构体一致
+func (m *MethodInfo) Accepts(params interface{}) *MethodInfo {
+	m.params = reflect.TypeOf(params)
+	return m
+}
+
+// AcceptsEach 可变数量的同类型参数
+func (m *MethodInfo) AcceptsEach(name string, item interface{}) *MethodInfo {
+	m.variadic = name
+	m.params = reflect.TypeOf(item)
+	return m
+}
+
+// Returns 成功时返回的数据类型
+func (m *MethodInfo) Returns(result interface{}) *MethodInfo {
+	m.result = reflect.TypeOf(result)
+	return m
+}
+
+// Streams 方法通过Stream返回部分结果
+func (m *MethodInfo) Streams() *MethodInfo {
+	m.streaming = true
+	return m
+}
+
+// 参数描述，对应OpenRPC的ContentDescriptor
+type paramInfo struct {
+	Name     string                 `json:"name"`
+	Required bool                   `json:"required,omitempty"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+// 按位置排列的参数描述
+func (m *MethodInfo) paramList() []paramInfo {
+	list := make([]paramInfo, 0)
+	switch {
+	case m.params == nil:
+	case m.variadic != "":
+		list = append(list, paramInfo{Name: m.variadic, Schema: tools.Schema(m.params)})
+	default:
+		t := m.params
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		for _, f := range tools.SchemaFields(t) {
+			s := tools.Schema(f.Type)
+			// 按位置传入时未标记omitempty的参数不可省略
+			required := f.Constrain(s) || !optional(f.StructField)
+			if variadic(f.StructField) {
+				// 可变参数按位置传入时逐个描述元素
+				s = tools.Schema(f.Type.Elem())
+			}
+			list = append(list, paramInfo{Name: f.Name, Required: required, Schema: s})
+		}
+	}
+	return list
+}
+
+// 参数结构体的最后一个字段是否为可变参数
+func (m *MethodInfo) hasVariadicField() bool {
+	if m.params == nil || m.variadic != "" {
+		return false
+	}
+	t := m.params
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	fields := tools.SchemaFields(t)
+	return len(fields) > 0 && variadic(fields[len(fields)-1].StructField)
+}
+
+// 参数对象的Schema，可变参数为数组
+func (m *MethodInfo) paramSchema() map[string]interface{} {
+	switch {
+	case m.params == nil:
+		return map[string]interface{}{"type": "array", "maxItems": 0}
+	case m.variadic != "":
+		return map[string]interface{}{"type": "array", "items": tools.Schema(m.params)}
+	default:
+		return tools.Schema(m.params)
+	}
+}
+
+// 已注册的方法，按名称排序
+func methods() []*MethodInfo {
+	list := make([]*MethodInfo, 0, len(catalog))
+	for _, m := range catalog {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// OpenRPC 导出所有ws方法的OpenRPC文档
+func OpenRPC(title string) map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(catalog))
+	for _, m := range methods() {
+		structure := "either"
+		if m.params == nil || m.variadic != "" {
+			structure = "by-position"
+		}
+		method := map[string]interface{}{
+			"name":           m.name,
+			"description":    m.description,
+			"paramStructure": structure,
+			"params":         m.paramList(),
+			"result":         paramInfo{Name: "result", Schema: tools.Schema(m.result)},
+		}
+		if m.variadic != "" || m.hasVariadicField() {
+			method["x-variadic"] = true
+		}
+		if len(m.permissions) > 0 {
+			method["x-permissions"] = m.permissions
+		}
+		if m.streaming {
+			method["x-streaming"] = true
+		}
+		list = append(list, method)
+	}
+	return map[string]interface{}{
+		"openrpc": "1.2.6",
+		"info":    map[string]interface{}{"title": title, "version": apiVersion},
+		"methods": list,
+	}
+}
+
+// AsyncAPI 导出所有ws方法的AsyncAPI文档，publish为客户端请求，subscribe为服务端回复
+func AsyncAPI(title string) map[string]interface{} {
+	channels := make(map[string]interface{}, len(catalog))
+	for _, m := range methods() {
+		channel := map[string]interface{}{
+			"description": m.description,
+			"publish": map[string]interface{}{
+				"operationId": m.name,
+				"message": map[string]interface{}{
+					"name":    m.name,
+					"payload": m.paramSchema(),
+				},
+			},
+			"subscribe": map[string]interface{}{
+				"operationId": m.name + ".reply",
+				"message": map[string]interface{}{
+					"name":    m.name + ".reply",
+					"payload": tools.Schema(m.result),
+				},
+			},
+		}
+		if len(m.permissions) > 0 {
+			channel["x-permissions"] = m.permissions
+		}
+		if m.streaming {
+			channel["x-streaming"] = true
+		}
+		channels[m.name] = channel
+	}
+	return map[string]interface{}{
+		"asyncapi":           "2.6.0",
+		"info":               map[string]interface{}{"title": title, "version": apiVersion},
+		"defaultContentType": "application/json",
+		"channels":           channels,
+	}
+}
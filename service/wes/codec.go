@@ -0,0 +1,266 @@
+package wes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ginWeb/utils/loguru"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 支持的报文编码
+const (
+	CodecJson     = "json"
+	CodecMsgpack  = "msgpack"
+	CodecProtobuf = "protobuf"
+)
+
+// Codec 报文编解码器，在JSON报文和底层帧之间转换，处理逻辑始终使用JSON报文
+type Codec interface {
+	Name() string
+	// MessageType 编码后的帧类型
+	MessageType() int
+	// Encode 将JSON报文编码为帧数据
+	Encode(data []byte) ([]byte, error)
+	// Decode 将帧数据解码为JSON报文
+	Decode(data []byte) ([]byte, error)
+}
+
+var codecs = map[string]Codec{
+	CodecJson:     jsonCodec{},
+	CodecMsgpack:  newMsgpackCodec(),
+	CodecProtobuf: protobufCodec{},
+}
+
+// 升级时可协商的子协议，按优先级排序
+var subprotocols = []string{CodecMsgpack, CodecProtobuf, CodecJson}
+
+// GetCodec 按名称获取编解码器，名称为空时返回JSON
+func GetCodec(name string) (Codec, bool) {
+	if name == "" {
+		name = CodecJson
+	}
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// JSON编码，原样收发文本帧
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return CodecJson }
+
+func (jsonCodec) MessageType() int { return websocket.TextMessage }
+
+func (jsonCodec) Encode(data []byte) ([]byte, error) { return data, nil }
+
+func (jsonCodec) Decode(data []byte) ([]byte, error) { return data, nil }
+
+// MessagePack编码
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgpackCodec() msgpackCodec {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return msgpackCodec{handle: h}
+}
+
+func (msgpackCodec) Name() string { return CodecMsgpack }
+
+func (msgpackCodec) MessageType() int { return websocket.BinaryMessage }
+
+func (m msgpackCodec) Encode(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	var out []byte
+	err := codec.NewEncoderBytes(&out, m.handle).Encode(normalizeNumber(v))
+	return out, err
+}
+
+func (m msgpackCodec) Decode(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := codec.NewDecoderBytes(data, m.handle).Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// 将json.Number转为整数或浮点数，保证大整数id不丢失精度
+func normalizeNumber(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = normalizeNumber(item)
+		}
+	case []interface{}:
+		for i, item := range t {
+			t[i] = normalizeNumber(item)
+		}
+	}
+	return v
+}
+
+// Protobuf编码，报文结构见frame.proto
+type protobufCodec struct{}
+
+// protobuf返回报文字段编号
+const (
+	respId protowire.Number = iota + 1
+	respMethod
+	respStatusCode
+	respData
+	respSeq
+)
+
+// protobuf请求报文字段编号
+const (
+	reqId protowire.Number = iota + 1
+	reqMethod
+	reqParams
+	reqNamed
+	reqSignature
+	reqTimestamp
+	reqNonce
+	reqTrace
+)
+
+var errProtobufFrame = errors.New("invalid protobuf frame")
+
+func (protobufCodec) Name() string { return CodecProtobuf }
+
+func (protobufCodec) MessageType() int { return websocket.BinaryMessage }
+
+// Encode 将Resp报文编码为Response
+func (protobufCodec) Encode(data []byte) ([]byte, error) {
+	var r struct {
+		Id         string          `json:"id"`
+		Method     string          `json:"method"`
+		StatusCode int32           `json:"statusCode"`
+		Data       json.RawMessage `json:"data"`
+		Seq        uint64          `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(data))
+	out = appendString(out, respId, r.Id)
+	out = appendString(out, respMethod, r.Method)
+	if r.StatusCode != 0 {
+		out = protowire.AppendTag(out, respStatusCode, protowire.VarintType)
+		out = protowire.AppendVarint(out, uint64(int64(r.StatusCode)))
+	}
+	if len(r.Data) > 0 && !bytes.Equal(r.Data, []byte("null")) {
+		out = protowire.AppendTag(out, respData, protowire.BytesType)
+		out = protowire.AppendBytes(out, r.Data)
+	}
+	if r.Seq != 0 {
+		out = protowire.AppendTag(out, respSeq, protowire.VarintType)
+		out = protowire.AppendVarint(out, r.Seq)
+	}
+	return out, nil
+}
+
+// Decode 将Request解码为JSON请求报文，未知字段忽略
+func (protobufCodec) Decode(data []byte) ([]byte, error) {
+	var req struct {
+		Id        string          `json:"id"`
+		Method    string          `json:"method"`
+		Params    json.RawMessage `json:"params,omitempty"`
+		Signature string          `json:"signature,omitempty"`
+		Timestamp int64           `json:"timestamp,omitempty"`
+		Nonce     string          `json:"nonce,omitempty"`
+		Trace     string          `json:"trace,omitempty"`
+	}
+	params := make([]json.RawMessage, 0)
+	var named []byte
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case typ == protowire.BytesType && num <= reqTrace && num != reqTimestamp:
+			var v []byte
+			v, n = protowire.ConsumeBytes(data)
+			switch num {
+			case reqId:
+				req.Id = string(v)
+			case reqMethod:
+				req.Method = string(v)
+			case reqParams:
+				if !json.Valid(v) {
+					return nil, errProtobufFrame
+				}
+				params = append(params, v)
+			case reqNamed:
+				named = v
+			case reqSignature:
+				req.Signature = string(v)
+			case reqNonce:
+				req.Nonce = string(v)
+			case reqTrace:
+				req.Trace = string(v)
+			}
+		case typ == protowire.VarintType && num == reqTimestamp:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			req.Timestamp = int64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	switch {
+	case len(named) > 0:
+		if !json.Valid(named) || bytes.TrimSpace(named)[0] != '{' {
+			return nil, errProtobufFrame
+		}
+		req.Params = named
+	case len(params) > 0:
+		req.Params, _ = json.Marshal(params)
+	}
+	return json.Marshal(req)
+}
+
+// 写入非空字符串字段
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// 按连接的编码生成帧，非JSON报文(如心跳)始终以文本帧发送
+func (c *Connection) frame(data []byte) (int, []byte) {
+	if c.codec.MessageType() == websocket.TextMessage || len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return websocket.TextMessage, data
+	}
+	out, err := c.codec.Encode(data)
+	if err != nil {
+		loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("encode %s frame to %s failed: %s", c.codec.Name(), c.IP, err.Error()))
+		return websocket.TextMessage, data
+	}
+	return c.codec.MessageType(), out
+}
@@ -0,0 +1,172 @@
+package wes
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 比较两段JSON是否等价
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	decode := func(data []byte) interface{} {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			t.Fatalf("decode %s: %v", data, err)
+		}
+		return v
+	}
+	return reflect.DeepEqual(decode(got), decode([]byte(want)))
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	tests := []string{
+		`{"id":"a","method":"m","params":[1,"x",true,null]}`,
+		`{"id":"a","method":"m","params":{"text":"x","n":1.5}}`,
+		`{"id":"a","method":"m","params":[9007199254740993]}`,
+		`[{"jsonrpc":"2.0","id":1,"method":"m"}]`,
+	}
+	m := newMsgpackCodec()
+	for _, msg := range tests {
+		encoded, err := m.Encode([]byte(msg))
+		if err != nil {
+			t.Fatalf("Encode(%s): %v", msg, err)
+		}
+		decoded, err := m.Decode(encoded)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", msg, err)
+		}
+		if !jsonEqual(t, decoded, msg) {
+			t.Fatalf("round trip %s = %s", msg, decoded)
+		}
+	}
+}
+
+// 按frame.proto构造请求报文
+func protoRequest(fields ...func([]byte) []byte) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = f(b)
+	}
+	return b
+}
+
+func protoBytes(num protowire.Number, v string) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	}
+}
+
+func protoVarint(num protowire.Number, v uint64) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	}
+}
+
+func TestProtobufDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+		err  bool
+	}{
+		{
+			name: "positional params",
+			data: protoRequest(protoBytes(reqId, "1"), protoBytes(reqMethod, "m"),
+				protoBytes(reqParams, `"a"`), protoBytes(reqParams, `{"b":2}`)),
+			want: `{"id":"1","method":"m","params":["a",{"b":2}]}`,
+		},
+		{
+			name: "named params override positional",
+			data: protoRequest(protoBytes(reqMethod, "m"), protoBytes(reqParams, `1`),
+				protoBytes(reqNamed, `{"text":"x"}`)),
+			want: `{"id":"","method":"m","params":{"text":"x"}}`,
+		},
+		{
+			name: "signature fields",
+			data: protoRequest(protoBytes(reqMethod, "m"), protoBytes(reqSignature, "sig"),
+				protoVarint(reqTimestamp, 1700000000000), protoBytes(reqNonce, "n"), protoBytes(reqTrace, "t")),
+			want: `{"id":"","method":"m","signature":"sig","timestamp":1700000000000,"nonce":"n","trace":"t"}`,
+		},
+		{
+			name: "unknown field ignored",
+			data: protoRequest(protoBytes(reqMethod, "m"), protoVarint(99, 1), protoBytes(98, "x")),
+			want: `{"id":"","method":"m"}`,
+		},
+		{
+			name: "invalid param json",
+			data: protoRequest(protoBytes(reqMethod, "m"), protoBytes(reqParams, `{`)),
+			err:  true,
+		},
+		{
+			name: "named params not an object",
+			data: protoRequest(protoBytes(reqMethod, "m"), protoBytes(reqNamed, `[1]`)),
+			err:  true,
+		},
+		{
+			name: "truncated",
+			data: protoRequest(protoBytes(reqMethod, "method"))[:4],
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := protobufCodec{}.Decode(tt.data)
+			if (err != nil) != tt.err {
+				t.Fatalf("Decode() err = %v, want error %v", err, tt.err)
+			}
+			if err == nil && !jsonEqual(t, got, tt.want) {
+				t.Fatalf("Decode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtobufEncode(t *testing.T) {
+	data, err := protobufCodec{}.Encode([]byte(`{"id":"1","method":"reply","statusCode":400,"data":{"a":[1,2]},"seq":7}`))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got := make(map[protowire.Number]interface{})
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			t.Fatalf("consume tag: %v", protowire.ParseError(n))
+		}
+		data = data[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			got[num] = string(v)
+			data = data[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			got[num] = v
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+	want := map[protowire.Number]interface{}{
+		respId:         "1",
+		respMethod:     "reply",
+		respStatusCode: uint64(400),
+		respData:       `{"a":[1,2]}`,
+		respSeq:        uint64(7),
+	}
+	for num, v := range want {
+		if got[num] != v {
+			t.Errorf("field %d = %v, want %v", num, got[num], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
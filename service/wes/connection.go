@@ -1,8 +1,10 @@
 package wes
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"ginWeb/config"
 	reCache "ginWeb/service/cache"
@@ -10,6 +12,7 @@ import (
 	"ginWeb/utils/auth"
 	"ginWeb/utils/loguru"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
@@ -27,7 +30,15 @@ var connectionLifeTime = time.Duration(config.Conf.Server.Websocket.WsLifeTime)
 // ws连接心跳检测周期
 var heartbeat = time.Duration(config.Conf.Server.Websocket.WsHeartbeat) * time.Second
 
+// 接收单条消息最大字节数，0为不限制
+var readLimit = config.Conf.Server.Websocket.ReadLimit
+
+// permessage-deflate压缩配置
+var compression = config.Conf.Server.Websocket.Compression
+
+// 子协议在UpgradeConn中按请求选择
 var upper = &websocket.Upgrader{
+	EnableCompression: compression.Enable,
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
@@ -44,18 +55,31 @@ var upper = &websocket.Upgrader{
 const managerHookName string = "connManager"
 
 var ConnManager = &connManager{
-	lock:        &sync.RWMutex{},
-	conns:       make(map[string]*Connection),
-	userConnMap: make(map[string]string),
+	lock:         &sync.RWMutex{},
+	conns:        make(map[string]*Connection),
+	userConnMap:  make(map[string][]string),
+	resumeTokens: make(map[string]string),
+	connectHooks: make(map[string]func(*Connection)),
 }
 
 type connManager struct {
-	lock        *sync.RWMutex
-	conns       map[string]*Connection // 连接uuid和连接对象的映射
-	userConnMap map[string]string      // 用户uuid和连接uuid的映射
+	lock         *sync.RWMutex
+	conns        map[string]*Connection       // 连接uuid和连接对象的映射
+	userConnMap  map[string][]string          // 用户uuid和连接uuid的映射，按连接先后排序
+	resumeTokens map[string]string            // 会话恢复令牌和连接uuid的映射
+	connectHooks map[string]func(*Connection) // 新连接建立后的钩子函数
+}
+
+// ConnOptions 客户端建立连接时指定的选项
+type ConnOptions struct {
+	Mac     string // 客户端mac
+	Ack     bool   // 推送是否携带序号并需要客户端确认
+	JsonRpc bool   // 推送是否使用JSON-RPC 2.0通知格式
+	Codec   Codec  // 报文编码，为空时使用JSON
 }
 
-func (m *connManager) New(conn *websocket.Conn, token *auth.Token, mac string) *Connection {
+// New 使用底层传输创建连接
+func (m *connManager) New(t transport, token *auth.Token, opts ConnOptions) *Connection {
 	// 创建生命周期管理上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	// 自动断开定时器
@@ -64,22 +88,32 @@ func (m *connManager) New(conn *websocket.Conn, token *auth.Token, mac string) *
 		timer = nil
 	} else {
 		timer = time.AfterFunc(connectionLifeTime, func() {
-			loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("connection lifetime over from %s", conn.RemoteAddr().String()))
+			loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("connection lifetime over from %s", t.remoteAddr()))
 			cancel()
 		})
 	}
+	if opts.Codec == nil {
+		opts.Codec = codecs[CodecJson]
+	}
 	c := &Connection{
-		conn:           conn,
+		conn:           t,
 		Uuid:           uuid.New().String(),
+		ResumeToken:    uuid.NewString(),
+		signKey:        newSignKey(),
+		Ack:            opts.Ack,
+		JsonRpc:        opts.JsonRpc,
+		codec:          opts.Codec,
+		pending:        make(map[uint64]*pendingPush),
+		inflight:       make(map[string]*WContext),
+		queueSignal:    make(chan struct{}, 1),
 		lifetimeCtx:    ctx,
 		cancel:         cancel,
 		lifetimeTimer:  timer,
-		msgChan:        make(chan *payload, config.Conf.Server.Websocket.WsMaxWaiting),
 		heartChan:      make(chan int64, config.Conf.Server.Websocket.WsMaxWaiting),
 		lock:           sync.RWMutex{},
 		connectTime:    time.Now(),
-		IP:             conn.RemoteAddr().String(),
-		MacAddress:     mac,
+		IP:             t.remoteAddr(),
+		MacAddress:     opts.Mac,
 		UserId:         token.UserId,
 		UserUuid:       token.UserUUID,
 		UserName:       token.Username,
@@ -90,26 +124,48 @@ func (m *connManager) New(conn *websocket.Conn, token *auth.Token, mac string) *
 		doneHooks: make(map[string]func()),
 		hookChain: make([]string, 0),
 	}
-	loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("connected from %s", conn.RemoteAddr()))
+	loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("connected from %s by %s", c.IP, t.name()))
 	// 开启连接的监听和处理函数
 	m.lock.Lock()
-	defer m.lock.Unlock()
-	m.userConn(c.UserUuid, c.Uuid)
+	evicted := m.userConn(c)
 	m.conns[c.Uuid] = c
+	m.resumeTokens[c.ResumeToken] = c.Uuid
 	c.DoneHook(managerHookName, func() {
 		m.lock.Lock()
 		defer m.lock.Unlock()
 		delete(m.conns, c.Uuid)
-		if m.userConnMap[c.UserUuid] == c.Uuid {
-			delete(m.userConnMap, c.UserUuid)
-		}
+		delete(m.resumeTokens, c.ResumeToken)
+		m.removeUserConn(c.UserUuid, c.Uuid)
 	})
-	go c.listen()
-	go c.handle()
+	hooks := make([]func(*Connection), 0, len(m.connectHooks))
+	for _, f := range m.connectHooks {
+		hooks = append(hooks, f)
+	}
+	m.lock.Unlock()
+	// 在锁外断开被替换的会话，其钩子函数可能访问管理器
+	for _, existConn := range evicted {
+		existConn.Disconnect()
+	}
+	c.sessionOpen()
+	t.attach(c)
+	go c.writeLoop()
 	c.heartbeat()
+	if c.Ack && ackRetryInterval > 0 {
+		go c.retryPending()
+	}
+	for _, f := range hooks {
+		go f(c)
+	}
 	return c
 }
 
+// ConnectHook 添加新连接建立后的钩子函数，钩子在独立协程中执行
+func (m *connManager) ConnectHook(key string, f func(*Connection)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.connectHooks[key] = f
+}
+
 func (m *connManager) Get(id string) (*Connection, bool) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -117,6 +173,33 @@ func (m *connManager) Get(id string) (*Connection, bool) {
 	return c, ok
 }
 
+// GetByUser 获取用户当前的所有连接，按连接先后排序
+func (m *connManager) GetByUser(userUuid string) []*Connection {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ids := m.userConnMap[userUuid]
+	conns := make([]*Connection, 0, len(ids))
+	for _, id := range ids {
+		if c, ok := m.conns[id]; ok {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// PushToUser 向用户所有连接推送消息，返回发送成功的连接数
+func (m *connManager) PushToUser(userUuid string, r Resp, critical bool) int {
+	sent := 0
+	for _, c := range m.GetByUser(userUuid) {
+		if err := c.Push(r, critical); err != nil {
+			loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("send to %s failed: %s", c.IP, err.Error()))
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
 // 存在的连接数
 func (m *connManager) Count() int {
 	m.lock.RLock()
@@ -124,21 +207,75 @@ func (m *connManager) Count() int {
 	return len(m.conns)
 }
 
-// 设置userUuid和连接id的对应关系，已存在则断开旧连接
-func (m *connManager) userConn(userUuid string, connId string) {
-	if existConnId, f := m.userConnMap[userUuid]; f {
-		// 已存在映射，则断开旧链接
-		if existConn, ok := m.conns[existConnId]; ok {
-			// 先删除钩子函数防止死锁
-			existConn.DeleteDoneHook(managerHookName)
-			// 清除映射
-			delete(m.conns, existConn.Uuid)
-			existConn.Disconnect()
+// 获取用户的会话策略，匹配用户权限中的第一个覆盖策略
+func sessionPolicy(permissions []string) config.SessionPolicy {
+	conf := config.Conf.Server.Websocket.Session
+	for _, p := range permissions {
+		if policy, ok := conf.Permissions[p]; ok {
+			return policy
+		}
+	}
+	return conf.SessionPolicy
+}
+
+// 设置userUuid和连接id的对应关系，按会话策略移除旧连接并返回，需在锁内调用
+func (m *connManager) userConn(c *Connection) []*Connection {
+	policy := sessionPolicy(c.UserPermission)
+	existIds := m.userConnMap[c.UserUuid]
+	kept := make([]string, 0, len(existIds)+1)
+	evicted := make([]*Connection, 0)
+	evict := func(id string) {
+		existConn, ok := m.conns[id]
+		if !ok {
+			return
+		}
+		// 先删除钩子函数防止死锁
+		existConn.DeleteDoneHook(managerHookName)
+		// 清除映射
+		delete(m.conns, id)
+		delete(m.resumeTokens, existConn.ResumeToken)
+		evicted = append(evicted, existConn)
+	}
+	for _, id := range existIds {
+		existConn, ok := m.conns[id]
+		switch {
+		case !ok:
+			continue
+		case policy.Mode == "multi":
+		case policy.Mode == "device" && existConn.MacAddress != c.MacAddress:
+		default:
+			// 单会话模式或同一设备的旧会话
+			evict(id)
+			continue
 		}
+		kept = append(kept, id)
 	}
-	// 更新为新连接
-	m.userConnMap[userUuid] = connId
+	// 超出最大会话数时断开最早的会话
+	if policy.MaxSessions > 0 {
+		for len(kept) >= policy.MaxSessions {
+			evict(kept[0])
+			kept = kept[1:]
+		}
+	}
+	// 添加新连接
+	m.userConnMap[c.UserUuid] = append(kept, c.Uuid)
+	return evicted
+}
 
+// 删除用户的连接映射，需在锁内调用
+func (m *connManager) removeUserConn(userUuid string, connId string) {
+	ids := m.userConnMap[userUuid]
+	for idx, id := range ids {
+		if id == connId {
+			ids = append(ids[:idx:idx], ids[idx+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(m.userConnMap, userUuid)
+		return
+	}
+	m.userConnMap[userUuid] = ids
 }
 
 // +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
@@ -148,19 +285,45 @@ func (m *connManager) userConn(userUuid string, connId string) {
 // Connection ws连接对象
 type Connection struct {
 	Uuid string
-	conn *websocket.Conn
+	conn transport // 底层传输，websocket或SSE
 	// 生命周期上下文
 	lifetimeCtx    context.Context
 	cancel         context.CancelFunc
-	lifetimeTimer  *time.Timer   // 生命周期定时器
-	lock           sync.RWMutex  // 对象读写锁
-	msgChan        chan *payload // 信息信道
-	heartChan      chan int64    // 心跳监测信道
-	disconnectOnce sync.Once     // 断开连接单次执行锁
+	lifetimeTimer  *time.Timer  // 生命周期定时器
+	lock           sync.RWMutex // 对象读写锁
+	heartChan      chan int64   // 心跳监测信道
+	disconnectOnce sync.Once    // 断开连接单次执行锁
+
+	// 会话暂存状态，网络异常断开后等待客户端恢复
+	parked    bool
+	parkTimer *time.Timer // 暂存超时定时器
+	missed    []outbound  // 暂存期间未发送的消息
+	dropped   int         // 超出缓存被丢弃的消息数
+
+	// 发送队列，由writeLoop写入底层连接
+	queue       [][]byte
+	queueSignal chan struct{}
+
+	// 协程池调度状态，由协程池的锁保护
+	tasks     []*WContext // 等待处理的请求
+	running   int         // 正在处理的请求数
+	scheduled bool        // 是否在协程池轮询队列中
+
+	// 处理中的请求，用于客户端取消
+	inflight map[string]*WContext
+
+	// 推送确认状态，仅Ack开启时使用
+	seq     uint64                  // 最后分配的推送序号
+	pending map[uint64]*pendingPush // 未确认的重要推送
 
 	// ==== 创建时初始化信息 不可变 =======
-	IP         string // 客户端IP
-	MacAddress string // 客户端mac
+	ResumeToken string // 会话恢复令牌
+	signKey     string // 请求签名密钥
+	Ack         bool   // 推送是否携带序号并需要客户端确认
+	JsonRpc     bool   // 推送是否使用JSON-RPC 2.0通知格式
+	codec       Codec  // 报文编码
+	IP          string // 客户端IP，恢复会话后不变
+	MacAddress  string // 客户端mac
 	// 登录信息
 	UserId         int64
 	UserUuid       string
@@ -191,25 +354,27 @@ func (c *Connection) ResetLifetime() {
 	}
 }
 
-// Send 发送消息
+// Send 消息加入发送队列，会话暂存期间缓存消息待恢复后重放，JSON-RPC连接的推送转为通知
 func (c *Connection) Send(data []byte) error {
+	if c.JsonRpc {
+		data = toNotification(data)
+	}
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	err := c.conn.WriteMessage(websocket.TextMessage, data)
-	if err != nil {
-		return err
+	if c.parked {
+		return c.buffer(outbound{data: data})
 	}
-	return nil
+	return c.enqueue(data)
 }
 
-// 将消息压入通道，压入失败则返回请求错误
+// 心跳响应压入通道，解析请求并提交至协程池，等待处理的请求已满则返回请求错误
 func (c *Connection) checkInMessage(isHeartbeat bool, msg []byte) {
 
 	if isHeartbeat {
 		select {
 		case c.heartChan <- time.Now().Unix():
 			return
-		case <-time.After(1 * time.Second):
+		default:
 			var m = Resp{
 				Id:         "",
 				Method:     "reply",
@@ -222,56 +387,86 @@ func (c *Connection) checkInMessage(isHeartbeat bool, msg []byte) {
 		}
 
 	}
+	if trimmed := bytes.TrimSpace(msg); len(trimmed) > 0 && trimmed[0] == '[' {
+		c.checkInBatch(trimmed)
+		return
+	}
 	var req payload
 	err := json.Unmarshal(msg, &req)
 	if err != nil {
-		msg := fmt.Sprintf("wrong message: %s", string(msg))
+		info := fmt.Sprintf("wrong message: %s", string(msg))
+		handleLog(dataType.WrongBody, c.IP, "-", info, 0)
+		if maybeRpc(msg) {
+			_ = c.Send(encodeRpc(nil, RpcParseError, "parse error", ""))
+			return
+		}
 		res, _ := json.Marshal(Resp{
 			Id:         "",
 			Method:     "reply",
 			StatusCode: dataType.WrongBody,
-			Data:       msg,
+			Data:       info,
 		})
 		_ = c.Send(res)
-		handleLog(dataType.WrongBody, c.conn.RemoteAddr().String(), "-", msg, 0)
 		return
 	}
-	select {
-	case c.msgChan <- &req:
+	if req.isRpc() && !req.rpcValid() {
+		_ = c.Send(encodeRpc(req.rpcId, RpcInvalidRequest, "invalid request", req.Method))
 		return
-	case <-time.After(1 * time.Second):
-		var m = Resp{
-			Id:         req.Id,
-			Method:     "reply",
-			StatusCode: dataType.TooManyRequests,
-			Data:       "too much request",
-		}
-		data, _ := json.Marshal(m)
-		_ = c.Send(data)
+	}
+	if req.Method == cancelMethod {
+		c.cancelRequest(&req)
+		return
+	}
+	c.submit(NewWContext(c, &req))
+}
+
+// 提交请求至协程池，等待处理的请求已满则返回请求过多
+// 提交时即记录请求，排队中的请求同样可被客户端取消
+func (c *Connection) submit(w *WContext) {
+	w.prepare()
+	c.track(w)
+	if pool.submit(w) {
 		return
 	}
+	c.untrack(w)
+	w.cancel(nil)
+	w.returnWith(dataType.TooManyRequests, "too much request")
 }
 
-// 开始接收数据
-func (c *Connection) listen() {
+// 开始接收websocket数据，恢复会话后使用新的底层连接重新开始
+func (c *Connection) listen(t *wsTransport) {
 	for {
-		// 读取失败，被动关闭连接
-		type_, message, err := c.conn.ReadMessage()
+		// 读取失败，暂存会话等待恢复
+		type_, message, err := t.conn.ReadMessage()
 		if err != nil {
+			// 消息超出大小限制，直接断开不保留会话
+			if errors.Is(err, websocket.ErrReadLimit) {
+				loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("message from %s exceeds read limit %d", c.IP, readLimit))
+				c.Disconnect()
+				break
+			}
 			loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("read message failed from %s: %s", c.IP, err.Error()))
-			c.Disconnect()
+			c.park(t)
 			break
 		}
 		switch type_ {
 		case websocket.TextMessage:
 			if len(message) < 10 && string(message) == "pong" {
-				go c.checkInMessage(true, message)
+				c.checkInMessage(true, message)
 				continue
 			}
-			go c.checkInMessage(false, message)
+			c.checkInMessage(false, message)
 		case websocket.BinaryMessage:
-			loguru.SimpleLog(loguru.Debug, "WS", "ignore binary message from: "+c.IP)
-			continue
+			if c.codec.MessageType() != websocket.BinaryMessage {
+				loguru.SimpleLog(loguru.Debug, "WS", "ignore binary message from: "+c.IP)
+				continue
+			}
+			data, err := c.codec.Decode(message)
+			if err != nil {
+				handleLog(dataType.WrongBody, c.IP, "-", fmt.Sprintf("decode %s frame failed: %s", c.codec.Name(), err.Error()), 0)
+				continue
+			}
+			c.checkInMessage(false, data)
 		case websocket.CloseMessage:
 			c.Disconnect()
 		case websocket.PingMessage:
@@ -283,26 +478,8 @@ func (c *Connection) listen() {
 	loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("close listen from %s", c.IP))
 }
 
-// 开始处理请求
-func (c *Connection) handle() {
-	for {
-		select {
-		// 生命周期结束
-		case <-c.lifetimeCtx.Done():
-			loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("close handle from %s by lifetime over", c.conn.RemoteAddr().String()))
-			return
-		case msg := <-c.msgChan:
-			// 单个请求的处理
-			ctx := NewWContext(c, msg)
-			// TODO 考虑使用公共协程池
-			go ctx.handle()
-
-		}
-	}
-}
-
-// 处理心跳检测返回信息，10秒超时关闭连接
-func (c *Connection) waitHeartbeat(tick int64) {
+// 处理心跳检测返回信息，10秒超时暂存会话
+func (c *Connection) waitHeartbeat(conn transport, tick int64) {
 	for {
 		select {
 		case <-c.lifetimeCtx.Done():
@@ -314,8 +491,8 @@ func (c *Connection) waitHeartbeat(tick int64) {
 			}
 			return
 		case <-time.After(10 * time.Second):
-			loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("heartbeat failed from %s", c.conn.RemoteAddr().String()))
-			c.Disconnect()
+			loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("heartbeat failed from %s", c.IP))
+			c.park(conn)
 			return
 		}
 	}
@@ -329,10 +506,15 @@ func (c *Connection) heartbeat() {
 		for {
 			select {
 			case t := <-ticker.C:
+				// 暂存期间或传输自身保活时不进行心跳检测
+				conn, ok := c.activeConn()
+				if !ok || !conn.heartbeat() {
+					continue
+				}
 				_ = c.Send([]byte("ping"))
-				go c.waitHeartbeat(t.Unix())
+				go c.waitHeartbeat(conn, t.Unix())
 			case <-c.lifetimeCtx.Done():
-				loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("close heartbeat from %s", c.conn.RemoteAddr().String()))
+				loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("close heartbeat from %s", c.IP))
 				return
 			}
 		}
@@ -382,54 +564,133 @@ func (c *Connection) Disconnect() {
 		defer c.lock.Unlock()
 
 		if c.conn != nil {
-			err := c.conn.Close()
+			err := c.conn.close()
 			if err != nil {
 				loguru.SimpleLog(loguru.Trace, "WS", "connect close err: "+err.Error())
 			}
 		}
+		if c.parkTimer != nil {
+			c.parkTimer.Stop()
+		}
+		c.parked = false
+		c.missed = nil
 		// 主动取消生命周期上下文
 		c.cancel()
 		loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("disconnect from %s, lifetime %s",
-			c.conn.RemoteAddr().String(), time.Since(c.connectTime).String()))
+			c.IP, time.Since(c.connectTime).String()))
 	})
 }
 
-// UpgradeConn ws路由升级函数
-func UpgradeConn(c *gin.Context) {
+// 校验连接请求的token，query中没有时使用Token请求头，失败时返回403
+func checkToken(c *gin.Context) (*auth.Token, bool) {
 	tokenS := c.Query("token")
+	if tokenS == "" {
+		tokenS = c.GetHeader("Token")
+	}
 	// 验证是否为黑名单Token
 	_, err := reCache.Get("blackToken", tokenS, nil)
 	if err == nil {
 		c.AbortWithStatusJSON(403, dataType.JsonWrong{
 			Code: dataType.BlackToken, Message: "invalid token",
 		})
-		return
+		return nil, false
 	}
 	token, _ := auth.CheckToken(tokenS)
 	if token == nil {
 		c.AbortWithStatusJSON(403, dataType.JsonWrong{
 			Code: dataType.Forbidden, Message: "invalid token",
 		})
+		return nil, false
+	}
+	return token, true
+}
+
+// UpgradeConn ws路由升级函数
+func UpgradeConn(c *gin.Context) {
+	token, ok := checkToken(c)
+	if !ok {
 		return
 	}
 
-	conn, err := upper.Upgrade(c.Writer, c.Request, nil)
+	// 编码优先使用codec参数，其次为协商的子协议
+	name := c.Query("codec")
+	if _, ok := GetCodec(name); !ok {
+		c.AbortWithStatusJSON(400, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: "unsupported codec",
+		})
+		return
+	}
+	jsonRpc := c.Query("protocol") == "jsonrpc"
+	// protobuf报文结构只对应原生格式
+	if jsonRpc && name == CodecProtobuf {
+		c.AbortWithStatusJSON(400, dataType.JsonWrong{
+			Code: dataType.WrongData, Message: "protobuf codec does not support jsonrpc",
+		})
+		return
+	}
+	var header http.Header
+	protocol := selectSubprotocol(c.Request, jsonRpc)
+	if protocol != "" {
+		header = http.Header{"Sec-Websocket-Protocol": []string{protocol}}
+	}
+	conn, err := upper.Upgrade(c.Writer, c.Request, header)
 	if err != nil {
 		c.AbortWithStatusJSON(200, dataType.JsonWrong{
 			Code: dataType.Unknown, Message: "upgrade failed",
 		})
 		return
 	}
+	if name == "" {
+		name = conn.Subprotocol()
+	}
+	codec, _ := GetCodec(name)
+	// 携带恢复令牌时优先恢复暂存的会话
+	t := &wsTransport{conn: conn}
+	if resumeToken := c.Query("resume"); resumeToken != "" {
+		if _, ok := ConnManager.Resume(resumeToken, token, t); ok {
+			return
+		}
+	}
+	ConnManager.New(t, token, ConnOptions{
+		Mac:     c.Query("mac"),
+		Ack:     c.Query("ack") == "true",
+		JsonRpc: jsonRpc,
+		Codec:   codec,
+	})
+}
+
+// 按优先级选择客户端支持的子协议，JSON-RPC不可使用protobuf
+func selectSubprotocol(r *http.Request, jsonRpc bool) string {
+	offered := websocket.Subprotocols(r)
+	for _, protocol := range subprotocols {
+		if jsonRpc && protocol == CodecProtobuf {
+			continue
+		}
+		if slices.Contains(offered, protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// 设置底层连接的读取限制、压缩等级、ping响应和关闭处理
+func bindHandlers(conn *websocket.Conn, connect *Connection) {
+	if readLimit > 0 {
+		conn.SetReadLimit(readLimit)
+	}
+	if compression.Enable {
+		if err := conn.SetCompressionLevel(compression.Level); err != nil {
+			loguru.SimpleLog(loguru.Error, "WS", fmt.Sprintf("set compression level failed: %s", err.Error()))
+		}
+	}
 	// 设置ping响应
 	conn.SetPingHandler(func(appData string) error {
 		loguru.SimpleLog(loguru.Trace, "WS", fmt.Sprintf("receive ping data '%s' from: %s", appData, conn.RemoteAddr().String()))
-		return conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+		return connect.Send([]byte("pong"))
 	})
-	connect := ConnManager.New(conn, token, c.Query("mac"))
-	// 设置连接关闭时调用管理对象的Disconnect方法
+	// 设置连接关闭时调用管理对象的Disconnect方法，客户端主动关闭不保留会话
 	conn.SetCloseHandler(func(code int, text string) error {
 		connect.Disconnect()
 		return nil
 	})
-
 }
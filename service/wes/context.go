@@ -7,8 +7,11 @@ import (
 	"ginWeb/config"
 	"ginWeb/service/dataType"
 	"ginWeb/utils/loguru"
+	"ginWeb/utils/tools"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // ws处理逻辑类型
@@ -22,17 +25,26 @@ func PrintTasks() {
 	if !config.Conf.Server.Debug {
 		return
 	}
-	for k, v := range tasks {
-		fmt.Printf("[WS-debug] %-10s (%v handlers)\n", k, len(*v))
+	for _, m := range methods() {
+		fmt.Printf("[WS-debug] %-24s (%v handlers) %s\n", m.name, len(*tasks[m.name]), m.description)
 	}
 }
 
 // 接收的ws报文
 type payload struct {
+	JsonRpc   string            `json:"jsonrpc,omitempty"` // JSON-RPC 2.0请求时为"2.0"
 	Id        string            `json:"id"`
 	Method    string            `json:"method"`
 	Params    []json.RawMessage `json:"params"`
+	Named     json.RawMessage   `json:"-"` // 按名称传入的参数对象
 	Signature string            `json:"signature"`
+	Timestamp int64             `json:"timestamp,omitempty"` // 签名时间戳(ms)
+	Nonce     string            `json:"nonce,omitempty"`     // 签名随机数，窗口期内不可重复
+	Trace     string            `json:"trace,omitempty"`     // 链路追踪id，为空时自动生成
+
+	rpcId     json.RawMessage // JSON-RPC请求的原始id
+	rawParams json.RawMessage // params原始文本，用于校验签名
+	notify    bool            // JSON-RPC通知，不返回结果
 }
 
 // Resp ws返回类型
@@ -41,6 +53,7 @@ type Resp struct {
 	Method     string      `json:"method"` // 响应类型，reply是被动回复
 	StatusCode int         `json:"statusCode"`
 	Data       interface{} `json:"data"`
+	Seq        uint64      `json:"seq,omitempty"` // 推送序号，连接开启确认时由Push分配
 }
 
 func (r *Resp) String() string {
@@ -64,11 +77,23 @@ type WContext struct {
 	Request   *payload
 	attribute map[string]interface{}
 
+	handlers handler // 处理逻辑链
+	index    int     // 当前执行的处理逻辑
+
 	statusCode int
 	response   interface{}
 	isAbort    bool       // 是否已退出
 	withResult bool       // 是否已设置结果
 	returnOnce *sync.Once // 返回结果的单次锁
+	batch      *rpcBatch  // 所属的JSON-RPC批量请求
+
+	streamLock sync.Mutex  // 保证部分结果在结束标记之前发送
+	streamed   bool        // 是否已发送部分结果
+	finished   bool        // 是否已返回最终结果
+	timer      *time.Timer // 处理超时计时器，发送部分结果时重置
+
+	ctx    context.Context         // 处理超时、客户端取消或连接断开时取消
+	cancel context.CancelCauseFunc // 取消处理
 }
 
 // Result 设置返回结果，终止后续处理逻辑
@@ -79,30 +104,89 @@ func (w *WContext) Result(code int, data interface{}) {
 	w.isAbort = true
 }
 
-// 返回数据，只会执行一次
-func (w *WContext) returnData(v []byte) {
+// 按请求格式编码返回结果，流式返回的请求以stream.end作为结束标记
+func (w *WContext) encodeReply(code int, data interface{}) []byte {
+	if w.Request.isRpc() {
+		return encodeRpc(w.Request.rpcId, code, data, w.Request.Method)
+	}
+	method := "reply"
+	if w.streamed {
+		method = "stream.end"
+	}
+	response := Resp{
+		Id:         w.Request.Id,
+		Method:     method,
+		StatusCode: code,
+		Data:       data,
+	}
+	res, flag := json.Marshal(response)
+	if flag != nil {
+		handleLog(dataType.WrongData, w.Conn.IP, w.Request.Method, "wrong return data", 0)
+	}
+	return res
+}
+
+// 编码设置的结果，未设置时返回nil
+func (w *WContext) result() []byte {
+	if !w.withResult {
+		return nil
+	}
+	return w.encodeReply(w.statusCode, w.response)
+}
+
+// 返回数据，只会执行一次，结束流式返回后编码结果，encode返回nil时不返回
+func (w *WContext) returnData(encode func() []byte) {
 	w.returnOnce.Do(func() {
-		if v != nil {
-			_ = w.Conn.Send(v)
-			return
+		w.streamLock.Lock()
+		w.finished = true
+		w.streamLock.Unlock()
+		var v []byte
+		if !w.Request.notify {
+			v = encode()
 		}
-		if !w.withResult {
+		if w.batch != nil {
+			w.batch.add(v)
 			return
 		}
-		response := Resp{
-			Id:         w.Request.Id,
-			Method:     "reply",
-			StatusCode: w.statusCode,
-			Data:       w.response,
-		}
-		data, flag := json.Marshal(response)
-		if flag != nil {
-			handleLog(dataType.WrongData, w.Conn.conn.RemoteAddr().String(), w.Request.Method, "wrong return data", 0)
+		if v != nil {
+			_ = w.Conn.Send(v)
 		}
-		_ = w.Conn.Send(data)
 	})
 }
 
+// 返回指定结果，用于超时、取消等处理逻辑之外的返回
+func (w *WContext) returnWith(code int, data interface{}) {
+	w.returnData(func() []byte {
+		return w.encodeReply(code, data)
+	})
+}
+
+// StatusCode 已设置的结果状态码
+func (w *WContext) StatusCode() int {
+	return w.statusCode
+}
+
+// Response 已设置的返回数据，未设置结果时返回false
+func (w *WContext) Response() (interface{}, bool) {
+	return w.response, w.withResult
+}
+
+// Next 执行后续处理逻辑，用于在中间件中观察或修改处理结果
+func (w *WContext) Next() {
+	w.index++
+	for ; w.index < len(w.handlers); w.index++ {
+		if w.isAbort || w.ctx.Err() != nil {
+			return
+		}
+		w.handlers[w.index](w)
+	}
+}
+
+// IsAborted 是否已中断处理
+func (w *WContext) IsAborted() bool {
+	return w.isAbort
+}
+
 // Abort 中断处理
 func (w *WContext) Abort() {
 	w.isAbort = true
@@ -119,61 +203,117 @@ func (w *WContext) Set(k string, v interface{}) {
 	w.attribute[k] = v
 }
 
-func (w *WContext) handle() {
+// 请求元数据，随上下文传递至数据库和缓存操作
+func (w *WContext) meta() *tools.RequestMeta {
+	trace := w.Request.Trace
+	if trace == "" {
+		trace = uuid.NewString()
+	}
+	return &tools.RequestMeta{
+		RequestId: w.Request.Id,
+		TraceId:   trace,
+		Method:    w.Request.Method,
+		UserId:    w.Conn.UserId,
+		UserUuid:  w.Conn.UserUuid,
+		UserName:  w.Conn.UserName,
+	}
+}
+
+// Ctx 请求上下文，处理超时、客户端取消或连接断开时取消，携带请求id、用户和链路追踪信息
+func (w *WContext) Ctx() context.Context {
+	return w.ctx
+}
+
+// Deadline 实现context.Context，处理超时由计时器控制，不设置截止时间
+func (w *WContext) Deadline() (time.Time, bool) {
+	return w.ctx.Deadline()
+}
+
+func (w *WContext) Done() <-chan struct{} {
+	return w.ctx.Done()
+}
 
+func (w *WContext) Err() error {
+	return w.ctx.Err()
+}
+
+func (w *WContext) Value(key any) any {
+	return w.ctx.Value(key)
+}
+
+// 创建请求上下文，提交至协程池前调用，排队期间即可被取消
+func (w *WContext) prepare() {
+	ctx, cancel := context.WithCancelCause(w.Conn.lifetimeCtx)
+	w.cancel = cancel
+	w.ctx = tools.WithMeta(ctx, w.meta())
+}
+
+// 结束跟踪并释放上下文，请求执行或丢弃后调用
+func (w *WContext) release() {
+	w.cancel(nil)
+	w.Conn.untrack(w)
+}
+
+// 在协程池中同步执行处理逻辑，超时或客户端取消后立即返回结果并取消上下文
+func (w *WContext) handle() {
+	defer w.release()
+	functions, ok := tasks[w.Request.Method]
+	if !ok {
+		w.Result(dataType.NotFound, "not found")
+		handleLog(1, w.Conn.IP, w.Request.Method, "not found", 0)
+		w.returnData(w.result)
+		return
+	}
+	ctx := w.ctx
+	// 排队期间被取消或连接已断开，不再执行
+	if ctx.Err() != nil {
+		if context.Cause(ctx) == errCanceled {
+			handleLog(dataType.WsCanceled, w.Conn.IP, w.Request.Method, "canceled", 0)
+			w.returnWith(dataType.WsCanceled, "canceled")
+		}
+		return
+	}
+	if handleTimeout > 0 {
+		w.timer = time.AfterFunc(handleTimeout, func() {
+			w.cancel(errHandleTimeout)
+		})
+		defer w.timer.Stop()
+	}
+	start := time.Now()
+	// 处理超时或被取消，连接断开时不返回
+	stop := context.AfterFunc(ctx, func() {
+		switch context.Cause(ctx) {
+		case errHandleTimeout:
+			handleLog(dataType.Timeout, w.Conn.IP, w.Request.Method, "timeout", time.Since(start))
+			w.returnWith(dataType.Timeout, "timeout")
+		case errCanceled:
+			handleLog(dataType.WsCanceled, w.Conn.IP, w.Request.Method, "canceled", time.Since(start))
+			w.returnWith(dataType.WsCanceled, "canceled")
+		}
+	})
 	defer func() {
 		if err := recover(); err != nil {
 			loguru.SimpleLog(loguru.Error, "WS", fmt.Sprintf("panic from ws handle: %v", err))
 			w.Result(dataType.WsResolveFailed, "resolve failed")
 		}
-	}()
-	if functions, ok := tasks[w.Request.Method]; ok {
-
-		// 任务处理计时器
-		doneCtx, done := context.WithCancel(context.Background())
-		start := time.Now()
-		// 同步执行处理逻辑
-		go func() {
-			for _, f := range *functions {
-				if w.isAbort {
-					break
-				}
-				f(w)
-			}
-			done()
-		}()
-
-		select {
-		// 处理完成
-		case <-doneCtx.Done():
-			cost := time.Since(start)
-			logInfo := "success"
-			if w.statusCode != dataType.Success {
-				logInfo = w.response.(string)
-			}
-			handleLog(w.statusCode, w.Conn.conn.RemoteAddr().String(), w.Request.Method, logInfo, cost)
-			w.returnData(nil)
-		// 处理超时
-		case <-time.After(handleTimeout):
-			handleLog(dataType.Timeout, w.Conn.conn.RemoteAddr().String(), w.Request.Method, "timeout", handleTimeout)
-			r := &Resp{
-				Id:         w.Request.Id,
-				Method:     "reply",
-				StatusCode: dataType.Timeout,
-				Data:       "timeout",
-			}
-			v, _ := json.Marshal(r)
-			w.returnData(v)
+		// 已超时或取消的请求不再返回结果
+		if !stop() {
+			return
 		}
-
-	} else {
-		w.Result(dataType.NotFound, "not found")
-		handleLog(1, w.Conn.conn.RemoteAddr().String(), w.Request.Method, "not found", 0)
-		w.returnData(nil)
-	}
+		logInfo := "success"
+		if w.statusCode != dataType.Success {
+			logInfo = fmt.Sprint(w.response)
+		}
+		handleLog(w.statusCode, w.Conn.IP, w.Request.Method, logInfo, time.Since(start))
+		w.returnData(w.result)
+	}()
+	w.handlers = *functions
+	w.index = -1
+	w.Next()
 }
 
 // NewWContext 创建ws上下文
 func NewWContext(conn *Connection, data *payload) *WContext {
-	return &WContext{Conn: conn, Request: data, attribute: make(map[string]any), returnOnce: &sync.Once{}}
+	return &WContext{Conn: conn, Request: data, attribute: make(map[string]any), returnOnce: &sync.Once{},
+		ctx: context.Background()}
 }
@@ -0,0 +1,181 @@
+package direct
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ginWeb/model/chatMode"
+	"ginWeb/model/systemMode"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/service/wes/moderate"
+	"ginWeb/utils/loguru"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSelfMessage  = errors.New("can not send message to yourself")
+	ErrBlocked      = errors.New("you are in the blacklist of target")
+	ErrUserNotFound = errors.New("target user not found")
+)
+
+// 离线消息投递的钩子函数名称
+const deliverHookName = "dm.deliver"
+
+// Messenger 私信管理器单例
+var Messenger = &messenger{}
+
+type messenger struct{}
+
+// Message 推送给接收者的私信
+type Message struct {
+	MessageId  string `json:"messageId"`
+	SenderUuid string `json:"senderUuid"`
+	SenderName string `json:"senderName"`
+	Timestamp  int64  `json:"timestamp"`
+	Data       string `json:"data"`
+}
+
+// Receipt 推送给发送者的送达/已读回执
+type Receipt struct {
+	MessageId  string `json:"messageId"`
+	TargetUuid string `json:"targetUuid"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+func toMessage(record *chatMode.DirectMessage) Message {
+	return Message{
+		MessageId:  record.Uuid,
+		SenderUuid: record.SenderUuid,
+		SenderName: record.SenderName,
+		Timestamp:  record.CreatedAt,
+		Data:       record.Content,
+	}
+}
+
+// 向用户所有会话推送，用户不在线或全部发送失败返回false
+func push(userUuid string, method string, id string, v interface{}) bool {
+	r := wes.Resp{
+		Id:         id,
+		Method:     method,
+		StatusCode: dataType.Success,
+		Data:       v,
+	}
+	if wes.ConnManager.PushToUser(userUuid, r, false) == 0 {
+		loguru.SimpleLog(loguru.Debug, "WS DM", fmt.Sprintf("push %s to %s failed: no session available", method, userUuid))
+		return false
+	}
+	return true
+}
+
+// Send 发送私信，接收者离线时保存，待其下次连接时投递，返回接收者是否在线
+// 推送成功不代表送达，接收者通过Received确认后才标记送达并推送送达回执
+func (m *messenger) Send(ctx context.Context, sender *wes.Connection, targetUuid string, text string) (Message, bool, error) {
+	if targetUuid == sender.UserUuid {
+		return Message{}, false, ErrSelfMessage
+	}
+	blocked, err := systemMode.ExistInList(targetUuid, sender.UserUuid)
+	if err != nil {
+		return Message{}, false, err
+	}
+	if blocked {
+		return Message{}, false, ErrBlocked
+	}
+	if len(wes.ConnManager.GetByUser(targetUuid)) == 0 {
+		if _, err := systemMode.GetUserByUuid(targetUuid); err != nil {
+			return Message{}, false, ErrUserNotFound
+		}
+	}
+	record := &chatMode.DirectMessage{
+		Uuid:       uuid.NewString(),
+		SenderUuid: sender.UserUuid,
+		SenderName: sender.UserName,
+		TargetUuid: targetUuid,
+		Content:    text,
+	}
+	if err := record.Create(ctx); err != nil {
+		return Message{}, false, err
+	}
+	msg := toMessage(record)
+	online := push(targetUuid, "publish.dm.message", msg.MessageId, msg)
+	return msg, online, nil
+}
+
+// Received 接收者确认收到私信，标记已送达并向在线的发送者推送送达回执，返回被标记的数量
+func (m *messenger) Received(ctx context.Context, receiver *wes.Connection, messageIds ...string) (int, error) {
+	msgs, err := chatMode.MarkDelivered(ctx, receiver.UserUuid, messageIds...)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range msgs {
+		push(msg.SenderUuid, "publish.dm.delivered", msg.Uuid, Receipt{
+			MessageId: msg.Uuid, TargetUuid: receiver.UserUuid, Timestamp: msg.DeliveredAt,
+		})
+	}
+	return len(msgs), nil
+}
+
+// Read 标记私信已读，并向在线的发送者推送已读回执，返回被标记的数量
+func (m *messenger) Read(ctx context.Context, reader *wes.Connection, messageIds ...string) (int, error) {
+	msgs, err := chatMode.MarkRead(ctx, reader.UserUuid, messageIds...)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range msgs {
+		push(msg.SenderUuid, "publish.dm.read", msg.Uuid, Receipt{
+			MessageId: msg.Uuid, TargetUuid: reader.UserUuid, Timestamp: msg.ReadAt,
+		})
+	}
+	return len(msgs), nil
+}
+
+// 按私信uuid查找被举报的私信，举报者需是接收者
+func resolveMessage(ctx context.Context, reporter *wes.Connection, _ string, messageId string) (*moderate.Snapshot, error) {
+	record, err := chatMode.GetDirectMessage(ctx, messageId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, moderate.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.TargetUuid != reporter.UserUuid {
+		return nil, moderate.ErrNotRecipient
+	}
+	return &moderate.Snapshot{
+		MessageId:  record.Uuid,
+		Scope:      "dm",
+		SenderUuid: record.SenderUuid,
+		SenderName: record.SenderName,
+		Content:    record.Content,
+		Timestamp:  record.CreatedAt,
+	}, nil
+}
+
+// 连接建立后投递未确认送达的私信，客户端需按messageId去重
+func (m *messenger) deliver(c *wes.Connection) {
+	msgs, err := chatMode.UndeliveredOf(c.UserUuid)
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "WS DM", fmt.Sprintf("load undelivered message of %s failed: %s", c.UserUuid, err.Error()))
+		return
+	}
+	for i := range msgs {
+		msg := toMessage(&msgs[i])
+		err := c.Push(wes.Resp{
+			Id:         msg.MessageId,
+			Method:     "publish.dm.message",
+			StatusCode: dataType.Success,
+			Data:       msg,
+		}, false)
+		if err != nil {
+			loguru.SimpleLog(loguru.Debug, "WS DM", fmt.Sprintf("deliver message to %s failed: %s", c.IP, err.Error()))
+			return
+		}
+	}
+}
+
+func init() {
+	wes.ConnManager.ConnectHook(deliverHookName, Messenger.deliver)
+	moderate.Moderation.Resolve("dm", resolveMessage)
+}
@@ -0,0 +1,191 @@
+package friend
+
+import (
+	"errors"
+	"fmt"
+	"ginWeb/model/systemMode"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/service/wes/subscribe"
+	"ginWeb/utils/loguru"
+	"time"
+)
+
+var (
+	ErrSelfRequest  = errors.New("can not add yourself as friend")
+	ErrBlocked      = errors.New("you are in the blacklist of target")
+	ErrUserNotFound = errors.New("target user not found")
+	ErrNotFriend    = errors.New("target is not your friend")
+	ErrNotInRoom    = errors.New("you are not in any room")
+	ErrNotOwner     = errors.New("only room owner can invite to a room with password or forbidden")
+)
+
+// 连接建立后订阅好友在线状态的钩子函数名称
+const presenceHookName = "friend.presence"
+
+// Friends 好友管理器单例
+var Friends = &friendManager{}
+
+type friendManager struct{}
+
+// Notice 推送给用户的好友变动通知
+type Notice struct {
+	UserUuid  string `json:"userUuid"`
+	UserName  string `json:"userName"`
+	Message   string `json:"message,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Invite 推送给好友的进房邀请
+type Invite struct {
+	UserUuid  string `json:"userUuid"`
+	UserName  string `json:"userName"`
+	RoomId    string `json:"roomId"`
+	RoomTitle string `json:"roomTitle"`
+	Link      string `json:"link,omitempty"` // 房主邀请时附带进房链接，可无视密码和关闭状态
+	Timestamp int64  `json:"timestamp"`
+}
+
+// 向用户所有会话推送，用户不在线或全部发送失败返回false
+func push(userUuid string, type_ string, v interface{}) bool {
+	return wes.ConnManager.PushToUser(userUuid, wes.Resp{
+		Id:         userUuid,
+		Method:     "publish.friend." + type_,
+		StatusCode: dataType.Success,
+		Data:       v,
+	}, false) > 0
+}
+
+// 双方所有会话互相订阅在线状态
+func watchEach(a string, b string) {
+	for _, conn := range wes.ConnManager.GetByUser(a) {
+		subscribe.Presences.Watch(conn, b)
+	}
+	for _, conn := range wes.ConnManager.GetByUser(b) {
+		subscribe.Presences.Watch(conn, a)
+	}
+}
+
+// 双方所有会话互相取消订阅在线状态
+func unwatchEach(a string, b string) {
+	for _, conn := range wes.ConnManager.GetByUser(a) {
+		subscribe.Presences.Unwatch(conn, b)
+	}
+	for _, conn := range wes.ConnManager.GetByUser(b) {
+		subscribe.Presences.Unwatch(conn, a)
+	}
+}
+
+// Request 发送好友申请，对方已向自己申请时直接成为好友，返回值表示是否已成为好友
+func (f *friendManager) Request(userUuid string, userName string, targetUuid string, message string) (bool, error) {
+	if userUuid == targetUuid {
+		return false, ErrSelfRequest
+	}
+	if _, err := systemMode.GetUserByUuid(targetUuid); err != nil {
+		return false, ErrUserNotFound
+	}
+	blocked, err := systemMode.ExistInList(targetUuid, userUuid)
+	if err != nil {
+		return false, err
+	}
+	if blocked {
+		return false, ErrBlocked
+	}
+	accepted, err := systemMode.RequestFriend(&systemMode.Friendship{
+		UserUuid: userUuid, UserName: userName, FriendUuid: targetUuid, Message: message,
+	})
+	if err != nil {
+		return false, err
+	}
+	notice := Notice{UserUuid: userUuid, UserName: userName, Message: message, Timestamp: time.Now().UnixMilli()}
+	if accepted {
+		push(targetUuid, "accept", notice)
+		watchEach(userUuid, targetUuid)
+	} else {
+		push(targetUuid, "request", notice)
+	}
+	return accepted, nil
+}
+
+// Reply 处理好友申请，accept为false时拒绝
+func (f *friendManager) Reply(userUuid string, userName string, requesterUuid string, accept bool) error {
+	if err := systemMode.ReplyFriend(userUuid, requesterUuid, accept); err != nil {
+		return err
+	}
+	notice := Notice{UserUuid: userUuid, UserName: userName, Timestamp: time.Now().UnixMilli()}
+	if !accept {
+		push(requesterUuid, "reject", notice)
+		return nil
+	}
+	push(requesterUuid, "accept", notice)
+	watchEach(userUuid, requesterUuid)
+	return nil
+}
+
+// Remove 删除好友
+func (f *friendManager) Remove(userUuid string, userName string, friendUuid string) error {
+	if err := systemMode.RemoveFriend(userUuid, friendUuid); err != nil {
+		return err
+	}
+	unwatchEach(userUuid, friendUuid)
+	push(friendUuid, "remove", Notice{UserUuid: userUuid, UserName: userName, Timestamp: time.Now().UnixMilli()})
+	return nil
+}
+
+// List 好友列表及其在线状态，拉黑了自己的好友显示为离线
+func (f *friendManager) List(userUuid string) ([]subscribe.PresenceInfo, error) {
+	friends, err := systemMode.FriendsOf(userUuid)
+	if err != nil {
+		return nil, err
+	}
+	if len(friends) == 0 {
+		return []subscribe.PresenceInfo{}, nil
+	}
+	return subscribe.Presences.QueryFor(userUuid, friends...), nil
+}
+
+// Invite 邀请好友进入自己所在的房间，返回好友是否在线
+// 房主邀请时附带进房链接，其他成员只能邀请进入公开房间
+func (f *friendManager) Invite(c *wes.Connection, friendUuid string) (bool, error) {
+	isFriend, err := systemMode.IsFriend(c.UserUuid, friendUuid)
+	if err != nil {
+		return false, err
+	}
+	if !isFriend {
+		return false, ErrNotFriend
+	}
+	room, ok := subscribe.Roomer.RoomOf(c)
+	if !ok {
+		return false, ErrNotInRoom
+	}
+	info := room.Info()
+	invite := Invite{
+		UserUuid:  c.UserUuid,
+		UserName:  c.UserName,
+		RoomId:    info.RoomID,
+		RoomTitle: info.RoomTitle,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if room.OwnerUuid() == c.UserUuid {
+		invite.Link = room.Link
+	} else if !room.Public() {
+		return false, ErrNotOwner
+	}
+	return push(friendUuid, "invite", invite), nil
+}
+
+// 连接建立后订阅所有好友的在线状态
+func (f *friendManager) watchFriends(c *wes.Connection) {
+	friends, err := systemMode.FriendsOf(c.UserUuid)
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "WS FRIEND", fmt.Sprintf("load friends of %s failed: %s", c.UserUuid, err.Error()))
+		return
+	}
+	if len(friends) > 0 {
+		subscribe.Presences.Watch(c, friends...)
+	}
+}
+
+func init() {
+	wes.ConnManager.ConnectHook(presenceHookName, Friends.watchFriends)
+}
@@ -3,31 +3,45 @@ package wes
 import (
 	"fmt"
 	"ginWeb/utils/loguru"
+	"slices"
+
+	"github.com/gin-gonic/gin"
 )
 
 // 已注册的ws处理逻辑
 var tasks = make(map[string]*handler)
 
-// RegisterHandler 注册ws处理函数，key已存则触发panic
-func RegisterHandler(key string, f ...handleFunc) {
+// RegisterHandler 注册ws处理函数，key已存则触发panic，返回方法描述用于补充文档
+func RegisterHandler(key string, f ...handleFunc) *MethodInfo {
 	if _, flag := tasks[key]; flag {
 		loguru.Logger.Fatalf("duplicate register ws handler: %s", key)
-		return
+		return nil
 	}
 	var h handler = f
 	tasks[key] = &h
-
+	info := &MethodInfo{name: key}
+	catalog[key] = info
+	return info
 }
 
 // Group ws处理组
 type Group struct {
 	node    string
 	middles []handleFunc
+	// 组内权限中间件所需的权限，注册时记入方法描述
+	permissions []string
+}
+
+// Guard 可描述所需权限的中间件，同时用于ws和http路由组
+type Guard interface {
+	HttpHandle(*gin.Context)
+	WsHandle(*WContext)
+	Permissions() []string
 }
 
 var BasicGroup = &Group{node: "", middles: []handleFunc{}}
 
-// Use 添加中间件
+// Use 添加中间件，中间件中调用Next可在后续处理逻辑结束后观察或修改结果
 func (g *Group) Use(f ...handleFunc) {
 	g.middles = append(g.middles, f...)
 }
@@ -41,20 +55,32 @@ func (g *Group) Group(name string, f ...handleFunc) *Group {
 		key = fmt.Sprintf("%s.%s", g.node, name)
 	}
 	return &Group{
-		node:    key,
-		middles: append(g.middles, f...),
+		node:        key,
+		middles:     append(g.middles, f...),
+		permissions: g.permissions,
+	}
+}
+
+// Guard 创建同名的子组并添加权限中间件，组内注册的方法记录其所需权限
+func (g *Group) Guard(guard Guard) *Group {
+	return &Group{
+		node:        g.node,
+		middles:     append(slices.Clip(g.middles), guard.WsHandle),
+		permissions: append(slices.Clip(g.permissions), guard.Permissions()...),
 	}
 }
 
-// Register 在组上创建处理函数
-func (g *Group) Register(route string, f ...handleFunc) {
+// Register 在组上创建处理函数，返回方法描述用于补充文档
+func (g *Group) Register(route string, f ...handleFunc) *MethodInfo {
 	var key string
 	if g.node == "" {
 		key = route
 	} else {
 		key = fmt.Sprintf("%s.%s", g.node, route)
 	}
-	RegisterHandler(key, append(g.middles, f...)...)
+	info := RegisterHandler(key, append(g.middles, f...)...)
+	info.permissions = g.permissions
+	return info
 }
 
 // NewGroup 在根组上新建组
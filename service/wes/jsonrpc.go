@@ -0,0 +1,192 @@
+package wes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"ginWeb/service/dataType"
+	"strings"
+	"sync"
+)
+
+const jsonRpcVersion = "2.0"
+
+// JSON-RPC 2.0 标准错误码
+const (
+	RpcParseError     = -32700
+	RpcInvalidRequest = -32600
+	RpcMethodNotFound = -32601
+	RpcInvalidParams  = -32602
+	RpcInternalError  = -32603
+)
+
+// RpcError JSON-RPC 2.0 错误对象
+type RpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// JSON-RPC 2.0 响应
+type rpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RpcError       `json:"error,omitempty"`
+}
+
+// JSON-RPC 2.0 通知，用于向JSON-RPC连接推送消息
+type rpcNotification struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// 推送通知的参数
+type pushParams struct {
+	Id   string      `json:"id"`
+	Data interface{} `json:"data"`
+	Seq  uint64      `json:"seq,omitempty"`
+}
+
+// 是否为JSON-RPC请求
+func (p *payload) isRpc() bool {
+	return p.JsonRpc != ""
+}
+
+// JSON-RPC请求是否合法
+func (p *payload) rpcValid() bool {
+	return p.JsonRpc == jsonRpcVersion && p.Method != ""
+}
+
+// 将状态码和返回数据转为JSON-RPC错误对象
+func rpcError(code int, data interface{}, method string) *RpcError {
+	e := &RpcError{Code: code}
+	switch code {
+	case dataType.NotFound:
+		if _, ok := tasks[method]; !ok {
+			e.Code = RpcMethodNotFound
+		}
+	case dataType.WrongBody:
+		e.Code = RpcInvalidParams
+	case dataType.WsResolveFailed:
+		e.Code = RpcInternalError
+	}
+	if msg, ok := data.(string); ok {
+		e.Message = msg
+	} else {
+		e.Message = "request failed"
+		e.Data = data
+	}
+	return e
+}
+
+// 编码JSON-RPC响应，id为空时返回null
+func encodeRpc(id json.RawMessage, code int, data interface{}, method string) []byte {
+	r := rpcResponse{JsonRpc: jsonRpcVersion, Id: id}
+	if code == dataType.Success {
+		r.Result = data
+		// 成功响应必须包含result
+		if data == nil {
+			r.Result = json.RawMessage("null")
+		}
+	} else {
+		r.Error = rpcError(code, data, method)
+	}
+	res, err := json.Marshal(r)
+	if err != nil {
+		handleLog(dataType.WrongData, "-", method, "wrong return data", 0)
+	}
+	return res
+}
+
+// 编码JSON-RPC通知
+func encodeNotification(r Resp) []byte {
+	data, _ := json.Marshal(rpcNotification{
+		JsonRpc: jsonRpcVersion,
+		Method:  r.Method,
+		Params:  pushParams{Id: r.Id, Data: r.Data, Seq: r.Seq},
+	})
+	return data
+}
+
+// 按连接的协议编码推送
+func (c *Connection) encodePush(r Resp) []byte {
+	if c.JsonRpc {
+		return encodeNotification(r)
+	}
+	data, _ := json.Marshal(r)
+	return data
+}
+
+// 将已编码的推送转为JSON-RPC通知，其他消息原样返回
+func toNotification(data []byte) []byte {
+	if len(data) == 0 || data[0] != '{' {
+		return data
+	}
+	var r struct {
+		JsonRpc string          `json:"jsonrpc"`
+		Id      string          `json:"id"`
+		Method  string          `json:"method"`
+		Data    json.RawMessage `json:"data"`
+		Seq     uint64          `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil || r.JsonRpc != "" || !strings.HasPrefix(r.Method, "publish.") {
+		return data
+	}
+	return encodeNotification(Resp{Id: r.Id, Method: r.Method, Data: r.Data, Seq: r.Seq})
+}
+
+// JSON-RPC批量请求，所有请求处理完成后一次性返回
+type rpcBatch struct {
+	lock      sync.Mutex
+	conn      *Connection
+	remaining int
+	replies   []json.RawMessage
+}
+
+// 记录一个请求的响应，通知请求传入nil
+func (b *rpcBatch) add(data []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if data != nil {
+		b.replies = append(b.replies, data)
+	}
+	b.remaining--
+	// 全部为通知时不返回
+	if b.remaining > 0 || len(b.replies) == 0 {
+		return
+	}
+	res, _ := json.Marshal(b.replies)
+	_ = b.conn.Send(res)
+}
+
+// 解析并提交JSON-RPC批量请求
+func (c *Connection) checkInBatch(msg []byte) {
+	var frames []json.RawMessage
+	if err := json.Unmarshal(msg, &frames); err != nil {
+		_ = c.Send(encodeRpc(nil, RpcParseError, "parse error", ""))
+		handleLog(RpcParseError, c.IP, "-", fmt.Sprintf("wrong batch: %s", err.Error()), 0)
+		return
+	}
+	if len(frames) == 0 {
+		_ = c.Send(encodeRpc(nil, RpcInvalidRequest, "invalid request", ""))
+		return
+	}
+	batch := &rpcBatch{conn: c, remaining: len(frames)}
+	for _, frame := range frames {
+		var req payload
+		if err := json.Unmarshal(frame, &req); err != nil || !req.rpcValid() {
+			batch.add(encodeRpc(req.rpcId, RpcInvalidRequest, "invalid request", req.Method))
+			continue
+		}
+		w := NewWContext(c, &req)
+		w.batch = batch
+		c.submit(w)
+	}
+}
+
+// 可能为JSON-RPC报文
+func maybeRpc(msg []byte) bool {
+	return bytes.Contains(msg, []byte(`"jsonrpc"`))
+}
@@ -0,0 +1,174 @@
+package wes
+
+import (
+	"encoding/json"
+	"fmt"
+	"ginWeb/service/dataType"
+	"slices"
+	"testing"
+	"time"
+)
+
+type echoParams struct {
+	Text string `json:"text" validate:"required"`
+}
+
+var _ = RegisterHandler("test.echo", Bind(func(w *WContext, p *echoParams) {
+	w.Result(dataType.Success, p.Text)
+}))
+
+func TestPayloadUnmarshal(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    string
+		id     string
+		rpcId  string
+		notify bool
+		params int
+		named  string
+		err    bool
+	}{
+		{name: "native", msg: `{"id":"a","method":"m","params":[1,2]}`, id: "a", params: 2},
+		{name: "native without id", msg: `{"method":"m"}`},
+		{name: "native null id", msg: `{"id":null,"method":"m"}`},
+		{name: "native numeric id", msg: `{"id":1,"method":"m"}`, err: true},
+		{name: "rpc string id", msg: `{"jsonrpc":"2.0","id":"a","method":"m"}`, id: "a", rpcId: `"a"`},
+		{name: "rpc numeric id", msg: `{"jsonrpc":"2.0","id":7,"method":"m"}`, id: "7", rpcId: "7"},
+		{name: "rpc notification", msg: `{"jsonrpc":"2.0","method":"m","params":[1]}`, notify: true, params: 1},
+		{name: "named params", msg: `{"id":"a","method":"m","params":{"text":"x"}}`, id: "a", named: `{"text":"x"}`},
+		{name: "invalid params", msg: `{"id":"a","method":"m","params":"x"}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p payload
+			err := json.Unmarshal([]byte(tt.msg), &p)
+			if (err != nil) != tt.err {
+				t.Fatalf("Unmarshal() err = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if p.Id != tt.id || string(p.rpcId) != tt.rpcId || p.notify != tt.notify {
+				t.Fatalf("id = %q, rpcId = %s, notify = %v, want %q, %s, %v", p.Id, p.rpcId, p.notify, tt.id, tt.rpcId, tt.notify)
+			}
+			if len(p.Params) != tt.params || string(p.Named) != tt.named {
+				t.Fatalf("params = %d, named = %s, want %d, %s", len(p.Params), p.Named, tt.params, tt.named)
+			}
+		})
+	}
+}
+
+// 批量请求的单个响应
+type batchReply struct {
+	Id     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *RpcError       `json:"error"`
+}
+
+// 响应的id和结果或错误码，用于比较
+func (r batchReply) String() string {
+	if r.Error != nil {
+		return fmt.Sprintf("%s:error %d", r.Id, r.Error.Code)
+	}
+	data, _ := json.Marshal(r.Result)
+	return fmt.Sprintf("%s:%s", r.Id, data)
+}
+
+// 等待连接发出消息，超时返回nil
+func waitSent(c *Connection, timeout time.Duration) [][]byte {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		c.lock.Lock()
+		queue := c.queue
+		c.lock.Unlock()
+		if len(queue) > 0 {
+			return queue
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestCheckInBatch(t *testing.T) {
+	oldWaiting := connMaxWaiting
+	connMaxWaiting = 16
+	defer func() { connMaxWaiting = oldWaiting }()
+
+	tests := []struct {
+		name string
+		msg  string
+		want []string // 批量响应，为nil时表示不返回
+		one  string   // 非批量的单个错误响应
+	}{
+		{
+			name: "parse error",
+			msg:  `[{"jsonrpc":"2.0"`,
+			one:  "null:error -32700",
+		},
+		{
+			name: "empty batch",
+			msg:  `[]`,
+			one:  "null:error -32600",
+		},
+		{
+			name: "invalid requests",
+			msg:  `[1, {"jsonrpc":"1.0","method":"test.echo","id":1}]`,
+			want: []string{"1:error -32600", "null:error -32600"},
+		},
+		{
+			name: "mixed",
+			msg: `[{"jsonrpc":"2.0","method":"test.echo","params":["a"],"id":1},
+				{"jsonrpc":"2.0","method":"test.echo","params":["b"]},
+				{"jsonrpc":"2.0","method":"test.missing","id":"x"},
+				{"jsonrpc":"2.0","method":"test.echo","params":[],"id":2}]`,
+			want: []string{`"x":error -32601`, `1:"a"`, "2:error -32602"},
+		},
+		{
+			name: "notifications only",
+			msg:  `[{"jsonrpc":"2.0","method":"test.echo","params":["a"]}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConn()
+			c.checkInBatch([]byte(tt.msg))
+			timeout := time.Second
+			if tt.want == nil && tt.one == "" {
+				timeout = 100 * time.Millisecond
+			}
+			sent := waitSent(c, timeout)
+			if tt.want == nil && tt.one == "" {
+				if sent != nil {
+					t.Fatalf("sent %s, want nothing", sent[0])
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sent))
+			}
+			if tt.one != "" {
+				var r batchReply
+				if err := json.Unmarshal(sent[0], &r); err != nil {
+					t.Fatalf("decode %s: %v", sent[0], err)
+				}
+				if got := r.String(); got != tt.one {
+					t.Fatalf("reply = %s, want %s", got, tt.one)
+				}
+				return
+			}
+			var replies []batchReply
+			if err := json.Unmarshal(sent[0], &replies); err != nil {
+				t.Fatalf("decode %s: %v", sent[0], err)
+			}
+			got := make([]string, 0, len(replies))
+			for _, r := range replies {
+				got = append(got, r.String())
+			}
+			// 批量请求并发处理，响应顺序不固定
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("replies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
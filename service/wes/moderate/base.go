@@ -0,0 +1,158 @@
+package moderate
+
+import (
+	"context"
+	"errors"
+	"ginWeb/config"
+	"ginWeb/model/chatMode"
+	"ginWeb/service/wes"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTooLong      = errors.New("message too long")
+	ErrFlood        = errors.New("sending messages too frequently")
+	ErrForbidden    = errors.New("message contains forbidden words")
+	ErrNotFound     = errors.New("message not found or expired")
+	ErrNotRecipient = errors.New("you did not receive this message")
+	ErrUnknownScope = errors.New("unknown message scope")
+)
+
+// Moderator 消息审核器，返回处理后的消息，返回错误则拒绝发送
+type Moderator interface {
+	Check(scope string, sender *wes.Connection, msg string) (string, error)
+}
+
+// Snapshot 被举报消息的快照
+type Snapshot struct {
+	MessageId  string
+	Scope      string
+	SenderUuid string
+	SenderName string
+	Content    string
+	Timestamp  int64
+}
+
+// Resolver 按消息id查找被举报的消息，并确认举报者是消息的接收者
+// target为房间uuid或频道名，私信为空
+type Resolver func(ctx context.Context, reporter *wes.Connection, target string, messageId string) (*Snapshot, error)
+
+// Moderation 消息审核链单例
+var Moderation = &moderation{
+	lock:      sync.RWMutex{},
+	chain:     make([]Moderator, 0),
+	resolvers: make(map[string]Resolver),
+	recent:    make(map[string]*Snapshot),
+	order:     make([]string, 0),
+	size:      config.Conf.Server.Moderation.RecentSize,
+}
+
+type moderation struct {
+	lock      sync.RWMutex
+	chain     []Moderator
+	resolvers map[string]Resolver  // 消息类型和对应的查找函数
+	recent    map[string]*Snapshot // 最近未持久化的消息，如频道消息
+	order     []string             // 消息先后顺序，用于淘汰
+	size      int
+}
+
+// Use 添加审核器，按添加顺序执行
+func (m *moderation) Use(mods ...Moderator) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.chain = append(m.chain, mods...)
+}
+
+// Resolve 注册消息类型的查找函数，kind为room、channel或dm
+func (m *moderation) Resolve(kind string, f Resolver) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.resolvers[kind] = f
+}
+
+// Check 执行审核链，scope为消息所在的房间或频道，通过后返回处理后的消息
+func (m *moderation) Check(scope string, sender *wes.Connection, msg string) (string, error) {
+	m.lock.RLock()
+	chain := m.chain
+	m.lock.RUnlock()
+	var err error
+	for _, mod := range chain {
+		msg, err = mod.Check(scope, sender, msg)
+		if err != nil {
+			return "", err
+		}
+	}
+	return msg, nil
+}
+
+// Remember 记录未持久化的消息用于举报，返回消息引用id
+func (m *moderation) Remember(scope string, sender *wes.Connection, msg string) string {
+	ref := uuid.NewString()
+	if m.size <= 0 {
+		return ref
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.recent[ref] = &Snapshot{
+		MessageId:  ref,
+		Scope:      scope,
+		SenderUuid: sender.UserUuid,
+		SenderName: sender.UserName,
+		Content:    msg,
+		Timestamp:  time.Now().UnixMilli(),
+	}
+	m.order = append(m.order, ref)
+	if len(m.order) > m.size {
+		delete(m.recent, m.order[0])
+		m.order = m.order[1:]
+	}
+	return ref
+}
+
+// Recent 获取最近记录的消息
+func (m *moderation) Recent(ref string) (*Snapshot, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	snap, ok := m.recent[ref]
+	return snap, ok
+}
+
+// Report 举报消息，kind为room、channel或dm，举报者需是消息的接收者
+func (m *moderation) Report(ctx context.Context, reporter *wes.Connection, kind string, target string, messageId string, reason string) error {
+	m.lock.RLock()
+	resolve, ok := m.resolvers[kind]
+	m.lock.RUnlock()
+	if !ok {
+		return ErrUnknownScope
+	}
+	snap, err := resolve(ctx, reporter, target, messageId)
+	if err != nil {
+		return err
+	}
+	report := &chatMode.MessageReport{
+		MessageId:    snap.MessageId,
+		Scope:        snap.Scope,
+		ReporterUuid: reporter.UserUuid,
+		SenderUuid:   snap.SenderUuid,
+		SenderName:   snap.SenderName,
+		Content:      snap.Content,
+		Reason:       reason,
+	}
+	return report.Create(ctx)
+}
+
+func init() {
+	conf := config.Conf.Server.Moderation
+	if conf.MaxLength > 0 {
+		Moderation.Use(NewLengthLimit(conf.MaxLength))
+	}
+	if conf.FloodCount > 0 && conf.FloodPeriod > 0 {
+		Moderation.Use(NewFloodLimit(conf.FloodCount, time.Duration(conf.FloodPeriod)*time.Second))
+	}
+	if len(conf.Words) > 0 || len(conf.Patterns) > 0 {
+		Moderation.Use(NewWordFilter(conf.Words, conf.Patterns, conf.Mode == "reject"))
+	}
+}
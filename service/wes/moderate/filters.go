@@ -0,0 +1,131 @@
+package moderate
+
+import (
+	"fmt"
+	"ginWeb/service/scheduler"
+	"ginWeb/service/wes"
+	"ginWeb/utils/loguru"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+// |                                           长度限制                                              |
+// +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+type lengthLimit struct {
+	max int
+}
+
+func (l *lengthLimit) Check(scope string, sender *wes.Connection, msg string) (string, error) {
+	if utf8.RuneCountInString(msg) > l.max {
+		return "", ErrTooLong
+	}
+	return msg, nil
+}
+
+// NewLengthLimit 消息最大字符数限制
+func NewLengthLimit(max int) Moderator {
+	return &lengthLimit{max: max}
+}
+
+// +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+// |                                           刷屏限制                                              |
+// +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+type floodBucket struct {
+	start time.Time
+	count int
+}
+
+type floodLimit struct {
+	lock    sync.Mutex
+	limit   int
+	period  time.Duration
+	buckets map[string]*floodBucket // scope和用户uuid对应的计数
+}
+
+func (f *floodLimit) Check(scope string, sender *wes.Connection, msg string) (string, error) {
+	key := scope + "::" + sender.UserUuid
+	now := time.Now()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	bucket, ok := f.buckets[key]
+	if !ok || now.Sub(bucket.start) >= f.period {
+		f.buckets[key] = &floodBucket{start: now, count: 1}
+		return msg, nil
+	}
+	if bucket.count >= f.limit {
+		return "", ErrFlood
+	}
+	bucket.count++
+	return msg, nil
+}
+
+// 清理过期的计数
+func (f *floodLimit) clean() {
+	now := time.Now()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for key, bucket := range f.buckets {
+		if now.Sub(bucket.start) >= f.period {
+			delete(f.buckets, key)
+		}
+	}
+}
+
+// NewFloodLimit 单个用户在同一房间/频道的period周期内最多发送limit条消息
+func NewFloodLimit(limit int, period time.Duration) Moderator {
+	f := &floodLimit{limit: limit, period: period, buckets: make(map[string]*floodBucket)}
+	_, err := scheduler.App.AddFunc("0 * * * * *", f.clean)
+	if err != nil {
+		loguru.SimpleLog(loguru.Fatal, "MODERATE", err.Error())
+	}
+	return f
+}
+
+// +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+// |                                           敏感词过滤                                            |
+// +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+type wordFilter struct {
+	patterns []*regexp.Regexp
+	reject   bool
+}
+
+func (w *wordFilter) Check(scope string, sender *wes.Connection, msg string) (string, error) {
+	for _, p := range w.patterns {
+		if !p.MatchString(msg) {
+			continue
+		}
+		if w.reject {
+			return "", ErrForbidden
+		}
+		msg = p.ReplaceAllStringFunc(msg, func(s string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(s))
+		})
+	}
+	return msg, nil
+}
+
+// NewWordFilter 敏感词过滤，words按字面匹配且忽略大小写，patterns为正则，reject为true时拒绝发送，否则替换为*
+func NewWordFilter(words []string, patterns []string, reject bool) Moderator {
+	w := &wordFilter{reject: reject, patterns: make([]*regexp.Regexp, 0, len(words)+len(patterns))}
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		w.patterns = append(w.patterns, regexp.MustCompile("(?i)"+regexp.QuoteMeta(word)))
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			loguru.SimpleLog(loguru.Fatal, "MODERATE", fmt.Sprintf("invalid moderation pattern %s: %s", p, err.Error()))
+		}
+		w.patterns = append(w.patterns, re)
+	}
+	return w
+}
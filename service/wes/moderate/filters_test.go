@@ -0,0 +1,123 @@
+package moderate
+
+import (
+	"errors"
+	"ginWeb/service/wes"
+	"testing"
+	"time"
+)
+
+func TestLengthLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		msg  string
+		err  error
+	}{
+		{"under limit", 5, "hello", nil},
+		{"over limit", 4, "hello", ErrTooLong},
+		{"counts runes", 2, "你好", nil},
+		{"runes over limit", 1, "你好", ErrTooLong},
+		{"empty", 0, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := NewLengthLimit(tt.max).Check("room", &wes.Connection{}, tt.msg)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && msg != tt.msg {
+				t.Fatalf("msg = %q, want %q", msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestFloodLimit(t *testing.T) {
+	f := &floodLimit{limit: 2, period: time.Minute, buckets: make(map[string]*floodBucket)}
+	alice := &wes.Connection{UserUuid: "alice"}
+	bob := &wes.Connection{UserUuid: "bob"}
+	steps := []struct {
+		name   string
+		scope  string
+		sender *wes.Connection
+		err    error
+	}{
+		{"first message", "room", alice, nil},
+		{"second message", "room", alice, nil},
+		{"third message flooded", "room", alice, ErrFlood},
+		{"other user counted apart", "room", bob, nil},
+		{"other scope counted apart", "channel", alice, nil},
+	}
+	for _, s := range steps {
+		if _, err := f.Check(s.scope, s.sender, "hi"); !errors.Is(err, s.err) {
+			t.Fatalf("%s: err = %v, want %v", s.name, err, s.err)
+		}
+	}
+
+	// 周期结束后重新计数
+	f.buckets["room::alice"].start = time.Now().Add(-time.Minute)
+	if _, err := f.Check("room", alice, "hi"); err != nil {
+		t.Fatalf("after period: err = %v, want nil", err)
+	}
+	f.buckets["channel::alice"].start = time.Now().Add(-time.Minute)
+	f.clean()
+	if _, ok := f.buckets["channel::alice"]; ok {
+		t.Fatal("clean kept an expired bucket")
+	}
+	if _, ok := f.buckets["room::alice"]; !ok {
+		t.Fatal("clean removed a live bucket")
+	}
+}
+
+func TestWordFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		words    []string
+		patterns []string
+		reject   bool
+		msg      string
+		want     string
+		err      error
+	}{
+		{"no match", []string{"bad"}, nil, false, "good day", "good day", nil},
+		{"masks word", []string{"bad"}, nil, false, "a bad day", "a *** day", nil},
+		{"ignores case", []string{"bad"}, nil, false, "BaD", "***", nil},
+		{"masks runes", []string{"坏话"}, nil, false, "说坏话", "说**", nil},
+		{"word is literal", []string{"a.c"}, nil, false, "abc a.c", "abc ***", nil},
+		{"skips empty word", []string{""}, nil, false, "text", "text", nil},
+		{"masks pattern", nil, []string{`\d{3}`}, false, "call 12345", "call ***45", nil},
+		{"rejects", []string{"bad"}, nil, true, "a bad day", "", ErrForbidden},
+		{"reject passes clean", []string{"bad"}, nil, true, "good", "good", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := NewWordFilter(tt.words, tt.patterns, tt.reject).Check("room", &wes.Connection{}, tt.msg)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if msg != tt.want {
+				t.Fatalf("msg = %q, want %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestModerationChain(t *testing.T) {
+	m := &moderation{}
+	m.Use(NewWordFilter([]string{"bad"}, nil, false), NewLengthLimit(5))
+	tests := []struct {
+		msg  string
+		want string
+		err  error
+	}{
+		{"bad", "***", nil},
+		{"too long", "", ErrTooLong},
+	}
+	for _, tt := range tests {
+		msg, err := m.Check("room", &wes.Connection{}, tt.msg)
+		if !errors.Is(err, tt.err) || msg != tt.want {
+			t.Fatalf("Check(%q) = %q, %v, want %q, %v", tt.msg, msg, err, tt.want, tt.err)
+		}
+	}
+}
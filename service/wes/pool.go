@@ -0,0 +1,104 @@
+package wes
+
+import (
+	"ginWeb/config"
+	"runtime"
+	"sync"
+)
+
+// 全局处理协程数量
+var workerCount = func() int {
+	if n := config.Conf.Server.Websocket.Workers; n > 0 {
+		return n
+	}
+	return runtime.NumCPU() * 8
+}()
+
+// 单个连接同时处理的请求数
+var connConcurrency = func() int {
+	if n := config.Conf.Server.Websocket.ConnConcurrency; n > 0 {
+		return n
+	}
+	return 4
+}()
+
+// 单个连接最大等待处理的请求数
+var connMaxWaiting = int(config.Conf.Server.Websocket.WsMaxWaiting)
+
+// 请求处理协程池，按连接轮询调度，单个连接无法占满所有协程
+var pool = newWorkerPool(workerCount)
+
+type workerPool struct {
+	lock  sync.Mutex
+	cond  *sync.Cond
+	ready []*Connection // 有待处理请求且未达到并发上限的连接
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{ready: make([]*Connection, 0)}
+	p.cond = sync.NewCond(&p.lock)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// 连接可继续调度时加入轮询队列，需在锁内调用
+func (p *workerPool) schedule(c *Connection) {
+	if c.scheduled || len(c.tasks) == 0 || c.running >= connConcurrency {
+		return
+	}
+	c.scheduled = true
+	p.ready = append(p.ready, c)
+	p.cond.Signal()
+}
+
+// 提交请求，连接等待处理的请求已满时返回false
+func (p *workerPool) submit(w *WContext) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	c := w.Conn
+	if len(c.tasks) >= connMaxWaiting {
+		return false
+	}
+	c.tasks = append(c.tasks, w)
+	p.schedule(c)
+	return true
+}
+
+// 取出下一个请求，每次只取一个后将连接放回队尾
+func (p *workerPool) next() *WContext {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for len(p.ready) == 0 {
+		p.cond.Wait()
+	}
+	c := p.ready[0]
+	p.ready = p.ready[1:]
+	c.scheduled = false
+	w := c.tasks[0]
+	c.tasks = c.tasks[1:]
+	c.running++
+	p.schedule(c)
+	return w
+}
+
+func (p *workerPool) done(c *Connection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	c.running--
+	p.schedule(c)
+}
+
+func (p *workerPool) work() {
+	for {
+		w := p.next()
+		// 连接已断开的请求不再执行，仍需结束跟踪并释放上下文
+		if w.Conn.lifetimeCtx.Err() == nil {
+			w.handle()
+		} else {
+			w.release()
+		}
+		p.done(w.Conn)
+	}
+}
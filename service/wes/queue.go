@@ -0,0 +1,132 @@
+package wes
+
+import (
+	"errors"
+	"fmt"
+	"ginWeb/config"
+	"ginWeb/utils/loguru"
+	"sync/atomic"
+	"time"
+)
+
+// 发送队列溢出策略
+const (
+	OverflowDropOldest = "dropOldest" // 丢弃最早的消息
+	OverflowDropNew    = "dropNew"    // 丢弃新消息
+	OverflowDisconnect = "disconnect" // 断开连接
+)
+
+var ErrQueueFull = errors.New("send queue is full")
+
+// 单个连接发送队列长度
+var sendQueueSize = func() int {
+	if size := config.Conf.Server.Websocket.SendQueue; size > 0 {
+		return size
+	}
+	return 256
+}()
+
+// 发送队列溢出策略
+var overflowPolicy = config.Conf.Server.Websocket.Overflow
+
+// 单条消息写入超时时间，0为不限制
+var writeTimeout = time.Duration(config.Conf.Server.Websocket.WriteTimeout) * time.Second
+
+var (
+	droppedTotal   atomic.Uint64 // 累计丢弃的消息数
+	overflowClosed atomic.Uint64 // 累计因队列溢出断开的连接数
+)
+
+// QueueStats 发送队列统计
+type QueueStats struct {
+	Queued     int    `json:"queued"`     // 当前所有连接排队的消息数
+	MaxDepth   int    `json:"maxDepth"`   // 单个连接最大排队数
+	Capacity   int    `json:"capacity"`   // 单个连接队列长度
+	Dropped    uint64 `json:"dropped"`    // 累计丢弃的消息数
+	Overflowed uint64 `json:"overflowed"` // 累计因队列溢出断开的连接数
+}
+
+// QueueStats 所有连接的发送队列统计
+func (m *connManager) QueueStats() QueueStats {
+	m.lock.RLock()
+	conns := make([]*Connection, 0, len(m.conns))
+	for _, c := range m.conns {
+		conns = append(conns, c)
+	}
+	m.lock.RUnlock()
+	stats := QueueStats{Capacity: sendQueueSize, Dropped: droppedTotal.Load(), Overflowed: overflowClosed.Load()}
+	for _, c := range conns {
+		depth := c.QueueDepth()
+		stats.Queued += depth
+		if depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+	}
+	return stats
+}
+
+// QueueDepth 发送队列中等待写入的消息数
+func (c *Connection) QueueDepth() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.queue)
+}
+
+// 消息加入发送队列，按溢出策略处理队列已满的情况，需在锁内调用
+func (c *Connection) enqueue(data []byte) error {
+	if len(c.queue) >= sendQueueSize {
+		switch overflowPolicy {
+		case OverflowDropNew:
+			droppedTotal.Add(1)
+			return ErrQueueFull
+		case OverflowDisconnect:
+			overflowClosed.Add(1)
+			loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("send queue overflow from %s, disconnect", c.IP))
+			// 断开连接需获取锁，在独立协程中执行
+			go c.Disconnect()
+			return ErrQueueFull
+		default:
+			c.queue = c.queue[1:]
+			droppedTotal.Add(1)
+		}
+	}
+	c.queue = append(c.queue, data)
+	select {
+	case c.queueSignal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// 发送队列写入协程，连接内唯一的写入者
+func (c *Connection) writeLoop() {
+	for {
+		select {
+		case <-c.lifetimeCtx.Done():
+			return
+		case <-c.queueSignal:
+		}
+		c.lock.Lock()
+		batch := c.queue
+		c.queue = nil
+		conn := c.conn
+		if c.parked {
+			// 会话已暂存，转入恢复缓存
+			for _, data := range batch {
+				_ = c.buffer(outbound{data: data})
+			}
+			c.lock.Unlock()
+			continue
+		}
+		c.lock.Unlock()
+		for i, data := range batch {
+			type_, frame := c.frame(data)
+			if err := conn.write(type_, frame); err != nil {
+				loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("write message to %s failed: %s", c.IP, err.Error()))
+				droppedTotal.Add(uint64(len(batch) - i))
+				c.park(conn)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package wes
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEnqueueOverflow(t *testing.T) {
+	oldSize, oldPolicy := sendQueueSize, overflowPolicy
+	defer func() { sendQueueSize, overflowPolicy = oldSize, oldPolicy }()
+	sendQueueSize = 2
+
+	tests := []struct {
+		policy     string
+		err        error
+		queue      []string
+		dropped    uint64
+		overflowed uint64
+		disconnect bool
+	}{
+		{policy: OverflowDropOldest, queue: []string{"b", "c"}, dropped: 1},
+		{policy: "", queue: []string{"b", "c"}, dropped: 1},
+		{policy: OverflowDropNew, err: ErrQueueFull, queue: []string{"a", "b"}, dropped: 1},
+		{policy: OverflowDisconnect, err: ErrQueueFull, queue: []string{"a", "b"}, overflowed: 1, disconnect: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			overflowPolicy = tt.policy
+			c := newTestConn()
+			dropped, overflowed := droppedTotal.Load(), overflowClosed.Load()
+			for _, data := range []string{"a", "b"} {
+				if err := c.enqueue([]byte(data)); err != nil {
+					t.Fatalf("enqueue %s: %v", data, err)
+				}
+			}
+			if err := c.enqueue([]byte("c")); !errors.Is(err, tt.err) {
+				t.Fatalf("enqueue on full queue: err = %v, want %v", err, tt.err)
+			}
+			got := make([]string, 0, len(c.queue))
+			for _, data := range c.queue {
+				got = append(got, string(data))
+			}
+			if !slices.Equal(got, tt.queue) {
+				t.Fatalf("queue = %v, want %v", got, tt.queue)
+			}
+			if n := droppedTotal.Load() - dropped; n != tt.dropped {
+				t.Fatalf("dropped = %d, want %d", n, tt.dropped)
+			}
+			if n := overflowClosed.Load() - overflowed; n != tt.overflowed {
+				t.Fatalf("overflowed = %d, want %d", n, tt.overflowed)
+			}
+			select {
+			case <-c.lifetimeCtx.Done():
+				if !tt.disconnect {
+					t.Fatal("connection closed on overflow")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.disconnect {
+					t.Fatal("connection not closed on overflow")
+				}
+			}
+		})
+	}
+}
+
+func TestEnqueueSignal(t *testing.T) {
+	c := newTestConn()
+	for _, data := range []string{"a", "b", "c"} {
+		if err := c.enqueue([]byte(data)); err != nil {
+			t.Fatalf("enqueue %s: %v", data, err)
+		}
+	}
+	// 多条消息合并为一次唤醒，写入协程一次取走整个队列
+	if n := len(c.queueSignal); n != 1 {
+		t.Fatalf("queued signals = %d, want 1", n)
+	}
+	if n := c.QueueDepth(); n != 3 {
+		t.Fatalf("QueueDepth() = %d, want 3", n)
+	}
+}
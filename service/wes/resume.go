@@ -0,0 +1,151 @@
+package wes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ginWeb/config"
+	"ginWeb/service/dataType"
+	"ginWeb/utils/auth"
+	"ginWeb/utils/loguru"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 网络异常断开后会话保留时间，0为不保留
+var resumeGrace = time.Duration(config.Conf.Server.Websocket.ResumeGrace) * time.Second
+
+// 会话暂存期间最多缓存的消息数
+var resumeBuffer = config.Conf.Server.Websocket.ResumeBuffer
+
+var ErrBufferFull = errors.New("resume buffer is full, message dropped")
+
+// SessionInfo 会话建立或恢复时推送给客户端的信息
+type SessionInfo struct {
+	SessionId   string `json:"sessionId"`
+	ResumeToken string `json:"resumeToken"` // 重连时通过resume参数传入以恢复会话
+	Grace       int64  `json:"grace"`       // 会话保留时间(s)
+	Replayed    int    `json:"replayed"`    // 恢复后重放的消息数
+	Dropped     int    `json:"dropped"`     // 超出缓存被丢弃的消息数
+	Ack         bool   `json:"ack"`         // 推送是否携带序号并需要确认
+	Codec       string `json:"codec"`       // 报文编码
+	Transport   string `json:"transport"`   // 底层传输 websocket sse
+	SignKey     string `json:"signKey"`     // 请求签名密钥(base64)
+}
+
+func (c *Connection) sessionInfo(method string, replayed int) []byte {
+	data, _ := json.Marshal(Resp{
+		Id:         c.Uuid,
+		Method:     method,
+		StatusCode: dataType.Success,
+		Data: SessionInfo{
+			SessionId:   c.Uuid,
+			ResumeToken: c.ResumeToken,
+			Grace:       int64(resumeGrace / time.Second),
+			Replayed:    replayed,
+			Dropped:     c.dropped,
+			Ack:         c.Ack,
+			Codec:       c.codec.Name(),
+			Transport:   c.conn.name(),
+			SignKey:     c.signKey,
+		},
+	})
+	return data
+}
+
+// 连接建立后推送会话恢复令牌和签名密钥
+func (c *Connection) sessionOpen() {
+	_ = c.Send(c.sessionInfo("publish.session.open", 0))
+}
+
+// 获取当前底层连接，会话暂存时返回false
+func (c *Connection) activeConn() (transport, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.conn, !c.parked
+}
+
+// 缓存暂存期间的消息，超出时丢弃最早的消息并返回ErrBufferFull，需在锁内调用
+func (c *Connection) buffer(out outbound) error {
+	if resumeBuffer <= 0 {
+		c.dropped++
+		return ErrBufferFull
+	}
+	var err error
+	if len(c.missed) >= resumeBuffer {
+		c.missed = c.missed[1:]
+		c.dropped++
+		err = ErrBufferFull
+	}
+	c.missed = append(c.missed, out)
+	return err
+}
+
+// 底层连接异常断开时暂存会话，超出保留时间后断开连接并执行所有钩子函数
+func (c *Connection) park(conn transport) {
+	if resumeGrace == 0 {
+		c.Disconnect()
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// 已恢复至新连接、已暂存或已断开时不处理
+	if c.conn != conn || c.parked || c.lifetimeCtx.Err() != nil {
+		return
+	}
+	c.parked = true
+	c.missed = make([]outbound, 0, len(c.queue))
+	c.dropped = 0
+	// 未写入的消息转入恢复缓存
+	for _, data := range c.queue {
+		_ = c.buffer(outbound{data: data})
+	}
+	c.queue = nil
+	_ = conn.close()
+	c.parkTimer = time.AfterFunc(resumeGrace, func() {
+		loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("session from %s not resumed in %s", c.IP, resumeGrace.String()))
+		c.Disconnect()
+	})
+	loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("session from %s parked", c.IP))
+}
+
+// 使用新的底层传输恢复暂存的会话，并重放暂存期间的消息
+func (c *Connection) resume(t transport) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// SSE只能发送文本，二进制编码的会话不可通过SSE恢复
+	if t.name() == TransportSSE && c.codec.MessageType() != websocket.TextMessage {
+		return false
+	}
+	// 定时器已触发说明会话正在断开
+	if !c.parked || c.lifetimeCtx.Err() != nil || !c.parkTimer.Stop() {
+		return false
+	}
+	c.conn = t
+	c.parked = false
+	// 在锁内加入队列，保证重放消息在新消息之前
+	replay := c.replayList()
+	_ = c.enqueue(c.sessionInfo("publish.session.resume", len(replay)))
+	for _, data := range replay {
+		if err := c.enqueue(data); err != nil {
+			break
+		}
+	}
+	c.missed = nil
+	c.dropped = 0
+	t.attach(c)
+	loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("session from %s resumed by %s via %s", c.IP, t.remoteAddr(), t.name()))
+	return true
+}
+
+// Resume 通过恢复令牌恢复同一用户暂存的会话
+func (m *connManager) Resume(resumeToken string, token *auth.Token, t transport) (*Connection, bool) {
+	m.lock.RLock()
+	c, ok := m.conns[m.resumeTokens[resumeToken]]
+	m.lock.RUnlock()
+	if !ok || c.UserUuid != token.UserUUID {
+		return nil, false
+	}
+	return c, c.resume(t)
+}
@@ -0,0 +1,74 @@
+package wes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"ginWeb/config"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrSignMissing = errors.New("signature, timestamp and nonce are required")
+	ErrSignExpired = errors.New("signature timestamp out of window")
+	ErrSignInvalid = errors.New("invalid signature")
+)
+
+// SignWindow 签名时间戳允许的偏差
+var SignWindow = func() time.Duration {
+	if w := config.Conf.Server.Websocket.Signature.Window; w > 0 {
+		return time.Duration(w) * time.Second
+	}
+	return 30 * time.Second
+}()
+
+// 生成会话签名密钥
+func newSignKey() string {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// 签名内容，按行拼接id、method、params、timestamp和nonce
+// params为报文中params的原始JSON文本，二进制编码时为解码后的紧凑JSON
+func (p *payload) signContent() []byte {
+	buf := make([]byte, 0, len(p.Id)+len(p.Method)+len(p.rawParams)+len(p.Nonce)+24)
+	buf = append(buf, p.Id...)
+	buf = append(buf, '\n')
+	buf = append(buf, p.Method...)
+	buf = append(buf, '\n')
+	buf = append(buf, p.rawParams...)
+	buf = append(buf, '\n')
+	buf = strconv.AppendInt(buf, p.Timestamp, 10)
+	buf = append(buf, '\n')
+	buf = append(buf, p.Nonce...)
+	return buf
+}
+
+// VerifySignature 校验请求签名，签名为会话密钥对签名内容的HMAC-SHA256十六进制值，timestamp为毫秒时间戳
+// 不检查nonce是否重复使用
+func (w *WContext) VerifySignature() error {
+	req := w.Request
+	if req.Signature == "" || req.Timestamp == 0 || req.Nonce == "" {
+		return ErrSignMissing
+	}
+	offset := time.Since(time.UnixMilli(req.Timestamp))
+	if offset > SignWindow || offset < -SignWindow {
+		return ErrSignExpired
+	}
+	signature, err := hex.DecodeString(req.Signature)
+	if err != nil {
+		return ErrSignInvalid
+	}
+	key, _ := base64.StdEncoding.DecodeString(w.Conn.signKey)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(req.signContent())
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ErrSignInvalid
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package wes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 按客户端的方式计算签名
+func signRequest(key string, id string, method string, params string, timestamp int64, nonce string) string {
+	raw, _ := base64.StdEncoding.DecodeString(key)
+	mac := hmac.New(sha256.New, raw)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n%d\n%s", id, method, params, timestamp, nonce)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	key := newSignKey()
+	now := time.Now().UnixMilli()
+	params := `["a", {"b": 1}]`
+	valid := signRequest(key, "1", "test", params, now, "n1")
+	tests := []struct {
+		name      string
+		params    string
+		signature string
+		timestamp int64
+		nonce     string
+		err       error
+	}{
+		{name: "valid", params: params, signature: valid, timestamp: now, nonce: "n1"},
+		{name: "valid without params", signature: signRequest(key, "1", "test", "", now, "n1"), timestamp: now, nonce: "n1"},
+		{name: "missing signature", params: params, timestamp: now, nonce: "n1", err: ErrSignMissing},
+		{name: "missing timestamp", params: params, signature: valid, nonce: "n1", err: ErrSignMissing},
+		{name: "missing nonce", params: params, signature: valid, timestamp: now, err: ErrSignMissing},
+		{
+			name: "expired", params: params, nonce: "n1",
+			timestamp: now - (SignWindow + time.Second).Milliseconds(),
+			signature: signRequest(key, "1", "test", params, now-(SignWindow+time.Second).Milliseconds(), "n1"),
+			err:       ErrSignExpired,
+		},
+		{
+			name: "from the future", params: params, nonce: "n1",
+			timestamp: now + (SignWindow + time.Second).Milliseconds(),
+			signature: signRequest(key, "1", "test", params, now+(SignWindow+time.Second).Milliseconds(), "n1"),
+			err:       ErrSignExpired,
+		},
+		{name: "not hex", params: params, signature: "zz", timestamp: now, nonce: "n1", err: ErrSignInvalid},
+		{name: "other nonce", params: params, signature: valid, timestamp: now, nonce: "n2", err: ErrSignInvalid},
+		{name: "tampered params", params: `["b", {"b": 1}]`, signature: valid, timestamp: now, nonce: "n1", err: ErrSignInvalid},
+		{name: "reformatted params", params: `["a",{"b":1}]`, signature: valid, timestamp: now, nonce: "n1", err: ErrSignInvalid},
+		{
+			name: "other key", params: params, timestamp: now, nonce: "n1",
+			signature: signRequest(newSignKey(), "1", "test", params, now, "n1"),
+			err:       ErrSignInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// params按原始文本写入报文，签名基于原始文本
+			msg := fmt.Sprintf(`{"id":"1","method":"test","signature":%q,"timestamp":%d,"nonce":%q`,
+				tt.signature, tt.timestamp, tt.nonce)
+			if tt.params != "" {
+				msg += `,"params":` + tt.params
+			}
+			msg += "}"
+			var p payload
+			if err := json.Unmarshal([]byte(msg), &p); err != nil {
+				t.Fatalf("decode %s: %v", msg, err)
+			}
+			w := &WContext{Conn: &Connection{signKey: key}, Request: &p}
+			if err := w.VerifySignature(); !errors.Is(err, tt.err) {
+				t.Fatalf("VerifySignature() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
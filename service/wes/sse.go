@@ -0,0 +1,189 @@
+package wes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"ginWeb/service/dataType"
+	"ginWeb/utils/loguru"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrTransportClosed = errors.New("transport is closed")
+
+// SSE保活注释发送周期，与心跳检测周期一致
+var sseKeepalive = func() time.Duration {
+	if heartbeat > 0 {
+		return heartbeat
+	}
+	return 30 * time.Second
+}()
+
+// SSE传输，推送以data事件写入响应流，客户端请求通过SSECall提交
+type sseTransport struct {
+	writer gin.ResponseWriter
+	ip     string
+	lock   sync.Mutex    // 保证消息和保活注释不交错写入
+	closed bool          // 关闭后不再写入，响应结束
+	done   chan struct{} // 关闭时结束SSE请求
+}
+
+func newSSETransport(w gin.ResponseWriter, ip string) *sseTransport {
+	return &sseTransport{writer: w, ip: ip, done: make(chan struct{})}
+}
+
+// 写入并刷新响应流，需在锁内调用
+func (t *sseTransport) flush(data []byte) error {
+	if t.closed {
+		return ErrTransportClosed
+	}
+	if writeTimeout > 0 {
+		_ = http.NewResponseController(t.writer).SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	if _, err := t.writer.Write(data); err != nil {
+		return err
+	}
+	t.writer.Flush()
+	return nil
+}
+
+// 每行消息作为一个data字段，客户端按换行拼接
+func (t *sseTransport) write(_ int, data []byte) error {
+	buf := make([]byte, 0, len(data)+16)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf = append(buf, "data: "...)
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, '\n')
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.flush(buf)
+}
+
+// 发送保活注释，客户端EventSource会忽略注释
+func (t *sseTransport) keepalive() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.flush([]byte(": ping\n\n"))
+}
+
+// 请求通过SSECall提交，无需监听
+func (t *sseTransport) attach(*Connection) {
+}
+
+func (t *sseTransport) heartbeat() bool {
+	return false
+}
+
+func (t *sseTransport) name() string {
+	return TransportSSE
+}
+
+func (t *sseTransport) remoteAddr() string {
+	return t.ip
+}
+
+func (t *sseTransport) close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+	return nil
+}
+
+// SSEConn SSE连接路由，用于无法建立websocket的网络，推送以SSE事件发送，请求通过SSECall提交
+// 连接选项与UpgradeConn一致，报文固定使用JSON编码
+func SSEConn(c *gin.Context) {
+	token, ok := checkToken(c)
+	if !ok {
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// 禁止反向代理缓冲响应
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	t := newSSETransport(c.Writer, c.Request.RemoteAddr)
+	var conn *Connection
+	resumed := false
+	// 携带恢复令牌时优先恢复暂存的会话
+	if resumeToken := c.Query("resume"); resumeToken != "" {
+		conn, resumed = ConnManager.Resume(resumeToken, token, t)
+	}
+	if !resumed {
+		conn = ConnManager.New(t, token, ConnOptions{
+			Mac:     c.Query("mac"),
+			Ack:     c.Query("ack") == "true",
+			JsonRpc: c.Query("protocol") == "jsonrpc",
+		})
+	}
+	ticker := time.NewTicker(sseKeepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-c.Request.Context().Done():
+			// 客户端断开，暂存会话等待恢复
+			loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("sse stream closed from %s", conn.IP))
+			conn.park(t)
+			return
+		case <-ticker.C:
+			if err := t.keepalive(); err != nil {
+				if !errors.Is(err, ErrTransportClosed) {
+					loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("sse keepalive to %s failed: %s", conn.IP, err.Error()))
+					conn.park(t)
+				}
+				return
+			}
+		}
+	}
+}
+
+// SSECall 提交SSE连接的请求，session为会话建立时推送的sessionId，请求体与ws报文一致
+// 请求进入与ws相同的处理流程，结果通过SSE推送返回
+func SSECall(c *gin.Context) {
+	token, ok := checkToken(c)
+	if !ok {
+		return
+	}
+	conn, ok := ConnManager.Get(c.Query("session"))
+	if !ok || conn.UserUuid != token.UserUUID {
+		c.AbortWithStatusJSON(http.StatusNotFound, dataType.JsonWrong{
+			Code: dataType.NotFound, Message: "session not found",
+		})
+		return
+	}
+	if readLimit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, readLimit)
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, dataType.JsonWrong{
+			Code: dataType.WrongBody, Message: "message exceeds read limit",
+		})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dataType.JsonWrong{
+			Code: dataType.WrongBody, Message: err.Error(),
+		})
+		return
+	}
+	conn.checkInMessage(false, body)
+	c.JSON(http.StatusAccepted, dataType.JsonRes{
+		Code: dataType.Success, Data: "accepted",
+	})
+}
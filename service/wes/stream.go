@@ -0,0 +1,115 @@
+package wes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ginWeb/service/dataType"
+)
+
+// 客户端取消请求的方法名，由框架直接处理，不进入协程池排队
+const cancelMethod = "cancel"
+
+var (
+	errHandleTimeout = errors.New("handle timeout")
+	errCanceled      = errors.New("canceled by client")
+
+	ErrStreamClosed = errors.New("stream is closed")
+)
+
+// 流式返回的部分结果，JSON-RPC请求以stream通知发送
+type streamParams struct {
+	Id   json.RawMessage `json:"id"`
+	Data interface{}     `json:"data"`
+}
+
+// Stream 在同一请求id下发送部分结果，处理结束时设置的结果作为结束标记(stream.end)返回
+// 每次发送重置处理超时，请求已结束、超时或被取消时返回错误
+func (w *WContext) Stream(data interface{}) error {
+	if w.ctx.Err() != nil {
+		return context.Cause(w.ctx)
+	}
+	w.streamLock.Lock()
+	defer w.streamLock.Unlock()
+	if w.finished {
+		return ErrStreamClosed
+	}
+	// 通知请求无法关联部分结果
+	if w.Request.notify {
+		return nil
+	}
+	w.streamed = true
+	if w.timer != nil {
+		w.timer.Reset(handleTimeout)
+	}
+	var v []byte
+	if w.Request.isRpc() {
+		v, _ = json.Marshal(rpcNotification{
+			JsonRpc: jsonRpcVersion,
+			Method:  "stream",
+			Params:  streamParams{Id: w.Request.rpcId, Data: data},
+		})
+	} else {
+		v, _ = json.Marshal(Resp{
+			Id:         w.Request.Id,
+			Method:     "stream",
+			StatusCode: dataType.Success,
+			Data:       data,
+		})
+	}
+	return w.Conn.Send(v)
+}
+
+// 记录排队或处理中的请求，用于客户端取消
+func (c *Connection) track(w *WContext) {
+	if w.Request.Id == "" {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inflight[w.Request.Id] = w
+}
+
+func (c *Connection) untrack(w *WContext) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.inflight[w.Request.Id] == w {
+		delete(c.inflight, w.Request.Id)
+	}
+}
+
+// Cancel 取消排队或处理中的请求，请求不存在或已结束返回false
+func (c *Connection) Cancel(id string) bool {
+	c.lock.RLock()
+	w, ok := c.inflight[id]
+	c.lock.RUnlock()
+	if !ok || w.ctx.Err() != nil {
+		return false
+	}
+	w.cancel(errCanceled)
+	return true
+}
+
+// 处理取消请求
+// params: [id: string | number]
+func (c *Connection) cancelRequest(req *payload) {
+	w := NewWContext(c, req)
+	var params struct {
+		Id json.RawMessage `json:"id" validate:"required"`
+	}
+	if err := w.Bind(&params); err != nil {
+		w.Result(dataType.WrongBody, err.Error())
+	} else {
+		w.Result(dataType.Success, c.Cancel(rawId(params.Id)))
+	}
+	w.returnData(w.result)
+}
+
+// 将请求id转为字符串，字符串id去除引号，数字id保持原文
+func rawId(id json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(id, &s); err != nil {
+		return string(id)
+	}
+	return s
+}
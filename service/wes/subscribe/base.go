@@ -1,6 +1,12 @@
 package subscribe
 
-import "ginWeb/service/wes"
+import (
+	"errors"
+	"ginWeb/service/wes"
+)
+
+// ErrAlreadySubscribed 重复订阅
+var ErrAlreadySubscribed = errors.New("already subscribed")
 
 // Pub 事件订阅接口类
 type Pub interface {
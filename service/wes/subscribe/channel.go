@@ -0,0 +1,158 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ginWeb/service/wes"
+	"ginWeb/utils/loguru"
+	"ginWeb/utils/tools"
+	"sort"
+	"sync"
+)
+
+// ChannelConfig 动态频道设置
+type ChannelConfig struct {
+	Name                string   `json:"name" validate:"required,min=2,max=64,excludesall=*#"` // 频道名，以.分隔层级
+	Description         string   `json:"description" validate:"max=128"`                       // 描述
+	SubscribePermission []string `json:"subscribePermission" validate:"max=8"`                 // 订阅所需权限
+	PublishPermission   []string `json:"publishPermission" validate:"max=8"`                   // 发言所需权限
+	Password            *string  `json:"password,omitempty" validate:"omitempty,max=16,min=4"` // 订阅密码
+}
+
+// ChannelInfo 接口返回的频道信息
+type ChannelInfo struct {
+	Name                string   `json:"name"`
+	Description         string   `json:"description"`
+	Dynamic             bool     `json:"dynamic"` // 是否为运行时创建
+	Creator             string   `json:"creator"`
+	SubscribePermission []string `json:"subscribePermission"`
+	PublishPermission   []string `json:"publishPermission"`
+	WithPassword        bool     `json:"withPassword"`
+	Subscribers         int      `json:"subscribers"`
+}
+
+// CreateChannel 运行时创建频道，creator为创建者用户uuid
+func (p *pubManager) CreateChannel(conf *ChannelConfig, creator string) (*Publisher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pub := &Publisher{
+		Name:        conf.Name,
+		subscribers: make(map[*wes.Connection]*Subscriber),
+		mutes:       make(map[string]MuteInfo),
+		lock:        sync.RWMutex{},
+		ctx:         ctx,
+		cancel:      cancel,
+		closed:      true,
+		acl:         conf,
+		creator:     creator,
+	}
+	p.lock.Lock()
+	if _, ok := p.pubs[conf.Name]; ok {
+		p.lock.Unlock()
+		cancel()
+		return nil, errors.New("channel already exists")
+	}
+	p.pubs[conf.Name] = pub
+	p.lock.Unlock()
+	_ = pub.Start("")
+	p.attachPatterns(pub)
+	loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("channel %s created by %s", conf.Name, creator))
+	return pub, nil
+}
+
+// DeleteChannel 删除运行时创建的频道，通知所有订阅者
+func (p *pubManager) DeleteChannel(name string) error {
+	pub, ok := p.GetPub(name)
+	if !ok {
+		return errors.New("channel not found")
+	}
+	if !pub.Dynamic() {
+		return errors.New("static channel can not be deleted")
+	}
+	p.DelPub(name)
+	pub.lock.RLock()
+	for c := range pub.subscribers {
+		c.DeleteDoneHook("publish." + pub.Name)
+		pub.noticeConn(c, "close", name)
+	}
+	pub.lock.RUnlock()
+	loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("channel %s deleted", name))
+	return pub.Shutdown()
+}
+
+// List 所有频道信息
+func (p *pubManager) List() []ChannelInfo {
+	p.lock.RLock()
+	pubs := make([]*Publisher, 0, len(p.pubs))
+	for _, pub := range p.pubs {
+		pubs = append(pubs, pub)
+	}
+	p.lock.RUnlock()
+	infos := make([]ChannelInfo, 0, len(pubs))
+	for _, pub := range pubs {
+		infos = append(infos, pub.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Dynamic 是否为运行时创建的频道
+func (p *Publisher) Dynamic() bool {
+	return p.acl != nil
+}
+
+// Creator 频道创建者用户uuid，静态频道为空
+func (p *Publisher) Creator() string {
+	return p.creator
+}
+
+func (p *Publisher) Info() ChannelInfo {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	info := ChannelInfo{
+		Name:                p.Name,
+		Dynamic:             p.acl != nil,
+		Creator:             p.creator,
+		SubscribePermission: []string{},
+		PublishPermission:   []string{},
+		Subscribers:         len(p.subscribers),
+	}
+	if p.acl != nil {
+		info.Description = p.acl.Description
+		info.SubscribePermission = p.acl.SubscribePermission
+		info.PublishPermission = p.acl.PublishPermission
+		info.WithPassword = p.acl.Password != nil && *p.acl.Password != ""
+	}
+	return info
+}
+
+// 检查订阅权限和密码，args[0]为可选的密码
+func (p *Publisher) checkSubscribe(c *wes.Connection, args ...any) error {
+	if p.acl == nil {
+		return nil
+	}
+	if !tools.ContainsAll(c.UserPermission, p.acl.SubscribePermission) {
+		return errors.New("permission denied")
+	}
+	if p.acl.Password == nil || *p.acl.Password == "" {
+		return nil
+	}
+	if len(args) == 0 {
+		return errors.New("password required")
+	}
+	if password, ok := args[0].(string); !ok || password != *p.acl.Password {
+		return errors.New("invalid password")
+	}
+	return nil
+}
+
+// 检查发言权限
+func (p *Publisher) checkPublish(c *wes.Connection) error {
+	if p.acl == nil || c == nil {
+		return nil
+	}
+	if !tools.ContainsAll(c.UserPermission, p.acl.PublishPermission) {
+		return errors.New("permission denied")
+	}
+	return nil
+}
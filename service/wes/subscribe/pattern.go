@@ -0,0 +1,188 @@
+package subscribe
+
+import (
+	"errors"
+	"fmt"
+	"ginWeb/service/wes"
+	"ginWeb/utils/loguru"
+	"strings"
+)
+
+// IsPattern 是否为通配订阅，*匹配单个层级，#匹配零个或多个层级
+func IsPattern(topic string) bool {
+	return strings.ContainsAny(topic, "*#")
+}
+
+// MatchTopic 判断层级频道名是否匹配通配符，层级以.分隔，如game.*.eu、game.#
+func MatchTopic(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "."), strings.Split(name, "."))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "#":
+			// 连续的#等价于一个
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(name) == 0 {
+				return false
+			}
+		default:
+			if len(name) == 0 || pattern[0] != name[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+// 校验通配符格式，通配符必须占据完整层级
+func validPattern(pattern string) bool {
+	for _, seg := range strings.Split(pattern, ".") {
+		if seg == "" {
+			return false
+		}
+		if strings.ContainsAny(seg, "*#") && len(seg) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func patternHookName(pattern string) string {
+	return "publish.pattern." + pattern
+}
+
+// SubscribePattern 通配订阅，订阅所有已存在和之后创建的匹配频道，返回本次新订阅的频道名
+func (p *pubManager) SubscribePattern(c *wes.Connection, pattern string) ([]string, error) {
+	if !validPattern(pattern) {
+		return nil, errors.New("invalid pattern")
+	}
+	p.lock.Lock()
+	patterns, ok := p.patterns[c]
+	if !ok {
+		patterns = make(map[string]map[string]struct{})
+		p.patterns[c] = patterns
+	}
+	if _, ok := patterns[pattern]; ok {
+		p.lock.Unlock()
+		return nil, ErrAlreadySubscribed
+	}
+	added := make(map[string]struct{})
+	patterns[pattern] = added
+	pubs := make([]*Publisher, 0)
+	for name, pub := range p.pubs {
+		if MatchTopic(pattern, name) {
+			pubs = append(pubs, pub)
+		}
+	}
+	p.lock.Unlock()
+
+	c.DoneHook(patternHookName(pattern), func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		p.dropPattern(c, pattern)
+	})
+	names := make([]string, 0, len(pubs))
+	for _, pub := range pubs {
+		// 已订阅或无权限的频道不记录，取消通配订阅时不会影响
+		if pub.Subscribe(c) != nil {
+			continue
+		}
+		names = append(names, pub.Name)
+	}
+	p.lock.Lock()
+	for _, name := range names {
+		added[name] = struct{}{}
+	}
+	p.lock.Unlock()
+	loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("user from %s subscribe pattern %s", c.IP, pattern))
+	return names, nil
+}
+
+// UnsubscribePattern 取消通配订阅，退订其添加的频道，仍被该连接其他通配订阅匹配的频道会保留
+func (p *pubManager) UnsubscribePattern(c *wes.Connection, pattern string) error {
+	p.lock.Lock()
+	added, ok := p.patterns[c][pattern]
+	if !ok {
+		p.lock.Unlock()
+		return errors.New("pattern not subscribed")
+	}
+	p.dropPattern(c, pattern)
+	remove := make([]*Publisher, 0, len(added))
+	for name := range added {
+		pub, ok := p.pubs[name]
+		if !ok {
+			continue
+		}
+		// 转交给其他仍匹配的通配订阅
+		transferred := false
+		for other, otherAdded := range p.patterns[c] {
+			if MatchTopic(other, name) {
+				otherAdded[name] = struct{}{}
+				transferred = true
+				break
+			}
+		}
+		if !transferred {
+			remove = append(remove, pub)
+		}
+	}
+	p.lock.Unlock()
+
+	c.DeleteDoneHook(patternHookName(pattern))
+	for _, pub := range remove {
+		_ = pub.UnSubscribe(c)
+	}
+	loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("user from %s unsubscribe pattern %s", c.IP, pattern))
+	return nil
+}
+
+// 删除通配订阅记录，需在锁内调用
+func (p *pubManager) dropPattern(c *wes.Connection, pattern string) {
+	delete(p.patterns[c], pattern)
+	if len(p.patterns[c]) == 0 {
+		delete(p.patterns, c)
+	}
+}
+
+// 新频道创建后，为匹配的通配订阅者添加订阅
+func (p *pubManager) attachPatterns(pub *Publisher) {
+	type match struct {
+		conn    *wes.Connection
+		pattern string
+	}
+	p.lock.RLock()
+	matches := make([]match, 0)
+	for c, patterns := range p.patterns {
+		for pattern := range patterns {
+			if MatchTopic(pattern, pub.Name) {
+				matches = append(matches, match{conn: c, pattern: pattern})
+				break
+			}
+		}
+	}
+	p.lock.RUnlock()
+	for _, m := range matches {
+		if pub.Subscribe(m.conn) != nil {
+			continue
+		}
+		p.lock.Lock()
+		if added, ok := p.patterns[m.conn][m.pattern]; ok {
+			added[pub.Name] = struct{}{}
+		}
+		p.lock.Unlock()
+	}
+}
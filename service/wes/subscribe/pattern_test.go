@@ -0,0 +1,80 @@
+package subscribe
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"game.eu", "game.eu", true},
+		{"game.eu", "game.us", false},
+		{"game.*", "game.eu", true},
+		{"game.*", "game", false},
+		{"game.*", "game.eu.lobby", false},
+		{"game.*.lobby", "game.eu.lobby", true},
+		{"game.*.lobby", "game.eu.room", false},
+		{"*", "game", true},
+		{"*", "game.eu", false},
+		{"game.#", "game", true},
+		{"game.#", "game.eu", true},
+		{"game.#", "game.eu.lobby", true},
+		{"game.#", "chat.eu", false},
+		{"#", "game.eu.lobby", true},
+		{"#.lobby", "lobby", true},
+		{"#.lobby", "game.eu.lobby", true},
+		{"#.lobby", "game.eu.room", false},
+		{"game.#.lobby", "game.lobby", true},
+		{"game.#.lobby", "game.eu.asia.lobby", true},
+		{"game.#.#", "game", true},
+		{"game.#.*", "game", false},
+		{"game.#.*", "game.eu", true},
+	}
+	for _, tt := range tests {
+		if got := MatchTopic(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"game.eu", true},
+		{"game.*", true},
+		{"game.#", true},
+		{"#", true},
+		{"*.eu.#", true},
+		{"", false},
+		{"game.", false},
+		{".game", false},
+		{"game..eu", false},
+		{"game.e*", false},
+		{"game.#eu", false},
+		{"game.**", false},
+	}
+	for _, tt := range tests {
+		if got := validPattern(tt.pattern); got != tt.want {
+			t.Errorf("validPattern(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"game.eu", false},
+		{"game.*", true},
+		{"game.#", true},
+	}
+	for _, tt := range tests {
+		if got := IsPattern(tt.topic); got != tt.want {
+			t.Errorf("IsPattern(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
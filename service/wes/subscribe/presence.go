@@ -0,0 +1,348 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ginWeb/model/systemMode"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/utils/database"
+	"ginWeb/utils/loguru"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	PresenceOffline = "offline"
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceInRoom  = "inRoom"
+)
+
+// 在线状态钩子函数名称
+const presenceHookName = "presence"
+
+// 订阅在线状态变化的钩子函数名称
+const presenceWatchHookName = "presence.watch"
+
+// redis中保存最后在线时间的hash
+const lastSeenKey = "::presence::lastSeen"
+
+// PresenceInfo 用户在线状态
+type PresenceInfo struct {
+	UserUuid string `json:"userUuid"`
+	State    string `json:"state"`
+	RoomId   string `json:"roomId,omitempty"` // 所在房间，仅未关闭入口且无密码的房间可见
+	LastSeen int64  `json:"lastSeen"`         // 最后在线时间戳(ms)，在线时为当前时间
+}
+
+type presenceState struct {
+	away       bool
+	roomId     string
+	roomPublic bool
+}
+
+// Presences 在线状态管理器单例
+var Presences = &presenceManager{
+	lock:     sync.RWMutex{},
+	states:   make(map[string]*presenceState),
+	sessions: make(map[string]int),
+	watchers: make(map[string]map[*wes.Connection]struct{}),
+	watching: make(map[*wes.Connection]map[string]struct{}),
+}
+
+type presenceManager struct {
+	lock     sync.RWMutex
+	states   map[string]*presenceState               // 在线用户的状态
+	sessions map[string]int                          // 在线用户的会话数量
+	watchers map[string]map[*wes.Connection]struct{} // 用户uuid和订阅其状态的连接
+	watching map[*wes.Connection]map[string]struct{} // 连接订阅的用户uuid
+}
+
+// 无锁获取在线用户状态
+func (p *presenceManager) infoFree(userUuid string) (PresenceInfo, bool) {
+	state, ok := p.states[userUuid]
+	if !ok {
+		return PresenceInfo{UserUuid: userUuid, State: PresenceOffline}, false
+	}
+	info := PresenceInfo{UserUuid: userUuid, State: PresenceOnline, LastSeen: time.Now().UnixMilli()}
+	if state.roomId != "" {
+		info.State = PresenceInRoom
+		if state.roomPublic {
+			info.RoomId = state.roomId
+		}
+	}
+	if state.away {
+		info.State = PresenceAway
+	}
+	return info, true
+}
+
+// Query 查询用户在线状态，离线用户从redis获取最后在线时间
+func (p *presenceManager) Query(userUuids ...string) []PresenceInfo {
+	infos := make([]PresenceInfo, 0, len(userUuids))
+	offline := make([]int, 0)
+	p.lock.RLock()
+	for _, userUuid := range userUuids {
+		info, online := p.infoFree(userUuid)
+		if !online {
+			offline = append(offline, len(infos))
+		}
+		infos = append(infos, info)
+	}
+	p.lock.RUnlock()
+	if len(offline) == 0 {
+		return infos
+	}
+	fields := make([]string, 0, len(offline))
+	for _, idx := range offline {
+		fields = append(fields, infos[idx].UserUuid)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	values, err := database.Rdb.HMGet(ctx, lastSeenKey, fields...).Result()
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "PRESENCE", "query last seen failed: "+err.Error())
+		return infos
+	}
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			infos[offline[i]].LastSeen, _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	return infos
+}
+
+// 批量判断users中哪些用户拉黑了viewer，被拉黑时不可见对方状态，查询失败时同样不可见
+func hiddenUsers(viewerUuid string, userUuids []string) map[string]bool {
+	hidden := make(map[string]bool, len(userUuids))
+	blocked, err := systemMode.InManyLists(userUuids, viewerUuid)
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "PRESENCE", "check blacklist failed: "+err.Error())
+	}
+	for i, userUuid := range userUuids {
+		hidden[userUuid] = err != nil || blocked[i]
+	}
+	return hidden
+}
+
+// 批量判断viewers中哪些用户被user拉黑，查询失败时均视为被拉黑
+func hiddenViewers(userUuid string, viewerUuids []string) map[string]bool {
+	hidden := make(map[string]bool, len(viewerUuids))
+	blocked, err := systemMode.ExistManyInList(userUuid, viewerUuids...)
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "PRESENCE", "check blacklist failed: "+err.Error())
+	}
+	for i, viewerUuid := range viewerUuids {
+		hidden[viewerUuid] = err != nil || blocked[i]
+	}
+	return hidden
+}
+
+// QueryFor 以viewerUuid身份查询用户在线状态，拉黑了viewer的用户显示为离线
+func (p *presenceManager) QueryFor(viewerUuid string, userUuids ...string) []PresenceInfo {
+	infos := p.Query(userUuids...)
+	hidden := hiddenUsers(viewerUuid, userUuids)
+	for i := range infos {
+		if hidden[infos[i].UserUuid] {
+			infos[i] = PresenceInfo{UserUuid: infos[i].UserUuid, State: PresenceOffline}
+		}
+	}
+	return infos
+}
+
+// SetAway 设置用户离开状态
+func (p *presenceManager) SetAway(userUuid string, away bool) error {
+	p.lock.Lock()
+	state, ok := p.states[userUuid]
+	if !ok {
+		p.lock.Unlock()
+		return errors.New("user offline")
+	}
+	changed := state.away != away
+	state.away = away
+	p.lock.Unlock()
+	if changed {
+		p.notify(userUuid)
+	}
+	return nil
+}
+
+// 设置用户所在房间，roomId为空时表示退出房间
+func (p *presenceManager) setRoom(userUuid string, roomId string, public bool) {
+	p.lock.Lock()
+	state, ok := p.states[userUuid]
+	if !ok {
+		if roomId == "" {
+			p.lock.Unlock()
+			return
+		}
+		// 重连回到房间可能早于在线钩子执行
+		state = &presenceState{}
+		p.states[userUuid] = state
+	}
+	if state.roomId == roomId && state.roomPublic == public {
+		p.lock.Unlock()
+		return
+	}
+	state.roomId = roomId
+	state.roomPublic = public
+	p.lock.Unlock()
+	go p.notify(userUuid)
+}
+
+// 退出指定房间，用户已在其他房间时不处理
+func (p *presenceManager) leaveRoom(userUuid string, roomId string) {
+	p.lock.RLock()
+	state, ok := p.states[userUuid]
+	inRoom := ok && state.roomId == roomId
+	p.lock.RUnlock()
+	if inRoom {
+		p.setRoom(userUuid, "", false)
+	}
+}
+
+// 连接建立后标记在线
+func (p *presenceManager) online(c *wes.Connection) {
+	p.lock.Lock()
+	p.sessions[c.UserUuid]++
+	if _, ok := p.states[c.UserUuid]; !ok {
+		p.states[c.UserUuid] = &presenceState{}
+	}
+	p.lock.Unlock()
+	touchLastSeen(c.UserUuid)
+	var once sync.Once
+	done := func() {
+		once.Do(func() { p.offline(c.UserUuid) })
+	}
+	c.DoneHook(presenceHookName, done)
+	// 钩子异步执行，注册前连接已断开时钩子不会再执行
+	select {
+	case <-c.Done():
+		done()
+		return
+	default:
+	}
+	p.notify(c.UserUuid)
+}
+
+// 会话断开，用户的最后一个会话断开时标记离线
+func (p *presenceManager) offline(userUuid string) {
+	p.lock.Lock()
+	p.sessions[userUuid]--
+	last := p.sessions[userUuid] <= 0
+	if last {
+		delete(p.sessions, userUuid)
+		delete(p.states, userUuid)
+	}
+	p.lock.Unlock()
+	if !last {
+		return
+	}
+	touchLastSeen(userUuid)
+	go p.notify(userUuid)
+}
+
+func touchLastSeen(userUuid string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	database.Rdb.HSet(ctx, lastSeenKey, userUuid, time.Now().UnixMilli())
+}
+
+// Watch 订阅用户在线状态变化，忽略拉黑了订阅者的用户
+func (p *presenceManager) Watch(c *wes.Connection, userUuids ...string) {
+	hidden := hiddenUsers(c.UserUuid, userUuids)
+	allowed := make([]string, 0, len(userUuids))
+	for _, userUuid := range userUuids {
+		if !hidden[userUuid] {
+			allowed = append(allowed, userUuid)
+		}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	targets, ok := p.watching[c]
+	if !ok {
+		targets = make(map[string]struct{})
+		p.watching[c] = targets
+		c.DoneHook(presenceWatchHookName, func() {
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			p.unwatchFree(c)
+		})
+	}
+	for _, userUuid := range allowed {
+		targets[userUuid] = struct{}{}
+		if _, ok := p.watchers[userUuid]; !ok {
+			p.watchers[userUuid] = make(map[*wes.Connection]struct{})
+		}
+		p.watchers[userUuid][c] = struct{}{}
+	}
+}
+
+// Unwatch 取消订阅用户在线状态变化，不传入用户时取消所有订阅
+func (p *presenceManager) Unwatch(c *wes.Connection, userUuids ...string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(userUuids) == 0 {
+		p.unwatchFree(c)
+		c.DeleteDoneHook(presenceWatchHookName)
+		return
+	}
+	for _, userUuid := range userUuids {
+		delete(p.watching[c], userUuid)
+		delete(p.watchers[userUuid], c)
+		if len(p.watchers[userUuid]) == 0 {
+			delete(p.watchers, userUuid)
+		}
+	}
+}
+
+func (p *presenceManager) unwatchFree(c *wes.Connection) {
+	for userUuid := range p.watching[c] {
+		delete(p.watchers[userUuid], c)
+		if len(p.watchers[userUuid]) == 0 {
+			delete(p.watchers, userUuid)
+		}
+	}
+	delete(p.watching, c)
+}
+
+// 向订阅者推送用户状态变化
+func (p *presenceManager) notify(userUuid string) {
+	info := p.Query(userUuid)[0]
+	p.lock.RLock()
+	conns := make([]*wes.Connection, 0, len(p.watchers[userUuid]))
+	for c := range p.watchers[userUuid] {
+		conns = append(conns, c)
+	}
+	p.lock.RUnlock()
+	if len(conns) == 0 {
+		return
+	}
+	r := wes.Resp{
+		Id:         userUuid,
+		Method:     "publish.presence.change",
+		StatusCode: dataType.Success,
+		Data:       info,
+	}
+	viewers := make([]string, 0, len(conns))
+	for _, c := range conns {
+		viewers = append(viewers, c.UserUuid)
+	}
+	hidden := hiddenViewers(userUuid, viewers)
+	for _, c := range conns {
+		// 订阅后被拉黑时不再推送
+		if hidden[c.UserUuid] {
+			continue
+		}
+		if err := c.Push(r, false); err != nil {
+			loguru.SimpleLog(loguru.Debug, "PRESENCE", fmt.Sprintf("push presence to %s failed: %s", c.IP, err.Error()))
+		}
+	}
+}
+
+func init() {
+	wes.ConnManager.ConnectHook(presenceHookName, Presences.online)
+}
@@ -8,6 +8,7 @@ import (
 	"ginWeb/service/dataType"
 	"ginWeb/service/scheduler"
 	"ginWeb/service/wes"
+	"ginWeb/service/wes/moderate"
 	"ginWeb/utils/loguru"
 	"sync"
 	"time"
@@ -21,11 +22,14 @@ import (
 
 var Publishers = &pubManager{
 	lock: sync.RWMutex{}, pubs: make(map[string]*Publisher),
+	patterns: make(map[*wes.Connection]map[string]map[string]struct{}),
 }
 
 type pubManager struct {
 	lock sync.RWMutex
 	pubs map[string]*Publisher
+	// 连接的通配订阅，通配符和由其添加订阅的频道名
+	patterns map[*wes.Connection]map[string]map[string]struct{}
 }
 
 // NewPublisher 注册并启动订阅事件，将f函数结果发送至每个订阅者，d为发送周期, d为空字符串时不会注册为定时事件
@@ -34,6 +38,7 @@ func (p *pubManager) NewPublisher(name string, d string, f ...func() string) *Pu
 	pub := &Publisher{
 		Name:        name,
 		subscribers: make(map[*wes.Connection]*Subscriber),
+		mutes:       make(map[string]MuteInfo),
 		lock:        sync.RWMutex{},
 		ctx:         ctx,
 		cancel:      cancel,
@@ -49,6 +54,7 @@ func (p *pubManager) NewPublisher(name string, d string, f ...func() string) *Pu
 		loguru.SimpleLog(loguru.Fatal, "WS", "failed start pub:"+err.Error())
 	}
 	p.SetPub(name, pub)
+	p.attachPatterns(pub)
 	return pub
 }
 
@@ -76,6 +82,7 @@ func (p *pubManager) DelPub(n string) {
 // +++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
 
 type publisherResp struct {
+	Ref        string      `json:"ref,omitempty"` // 消息引用id，用于举报，系统消息为空
 	SenderId   int64       `json:"senderId"`
 	SenderName string      `json:"senderName"`
 	SenderUuid string      `json:"senderUuid"`
@@ -89,12 +96,31 @@ type Subscriber struct {
 	Muted bool
 }
 
+// MuteInfo 禁言信息
+type MuteInfo struct {
+	Channel  string `json:"channel"`
+	UserUuid string `json:"userUuid"`
+	Until    int64  `json:"until"` // 解除时间戳(ms)，0为永久
+	Reason   string `json:"reason"`
+	Operator string `json:"operator"` // 操作者用户uuid
+}
+
+func (m MuteInfo) expired() bool {
+	return m.Until != 0 && m.Until <= time.Now().UnixMilli()
+}
+
 // Publisher 订阅事件
 type Publisher struct {
 	Name string
 
 	// 订阅事件的ws连接
 	subscribers map[*wes.Connection]*Subscriber
+	// 被禁言的用户uuid，重新订阅或重连后仍然生效，仅保存在内存中，服务重启后失效
+	mutes map[string]MuteInfo
+	// 动态频道的访问设置，静态频道为nil
+	acl *ChannelConfig
+	// 动态频道创建者用户uuid
+	creator string
 	// 对象读写锁
 	lock sync.RWMutex
 	// 开启状态
@@ -112,12 +138,15 @@ func (p *Publisher) Subscribe(c *wes.Connection, args ...any) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	if _, ok := p.subscribers[c]; ok {
-		return errors.New("already subscribed")
+		return ErrAlreadySubscribed
+	}
+	if err := p.checkSubscribe(c, args...); err != nil {
+		return err
 	}
 	p.subscribers[c] = &Subscriber{
 		Conn:  c,
 		Pub:   p,
-		Muted: false,
+		Muted: p.mutedFree(c.UserUuid),
 	}
 	loguru.SimpleLog(loguru.Debug, "WS", fmt.Sprintf("user from %s subscribe channel %s", c.IP, p.Name))
 	c.DoneHook("publish."+p.Name, func() {
@@ -147,12 +176,26 @@ func (p *Publisher) UnSubscribe(c *wes.Connection, args ...any) error {
 	return nil
 }
 
-// Message 发送包装后的消息响应
+// Message 发送包装后的消息响应，sender不为空时消息需通过审核
 func (p *Publisher) Message(v string, sender *wes.Connection) error {
 	var id int64
 	var name string
 	var uuid string
+	var ref string
 	if sender != nil {
+		// 先检查禁言和权限，被拒绝的消息不计入刷屏统计也不记录
+		p.lock.RLock()
+		err := p.sendableFree(sender)
+		p.lock.RUnlock()
+		if err != nil {
+			return err
+		}
+		v, err = moderate.Moderation.Check("channel:"+p.Name, sender, v)
+		if err != nil {
+			return err
+		}
+		// 频道消息不持久化，记录在最近消息中用于举报
+		ref = moderate.Moderation.Remember("channel:"+p.Name, sender, v)
 		id = sender.UserId
 		name = sender.UserName
 		uuid = sender.Uuid
@@ -162,6 +205,7 @@ func (p *Publisher) Message(v string, sender *wes.Connection) error {
 		Method:     fmt.Sprintf("publish.%s", p.Name),
 		StatusCode: dataType.Success,
 		Data: publisherResp{
+			Ref:        ref,
 			SenderId:   id,
 			SenderName: name,
 			SenderUuid: uuid,
@@ -176,15 +220,11 @@ func (p *Publisher) Message(v string, sender *wes.Connection) error {
 func (p *Publisher) Publish(v []byte, sender *wes.Connection) error {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	if p.closed {
-		return errors.New("publish forbidden")
-	}
 	if len(v) == 0 {
 		return errors.New("msg is empty")
 	}
-
-	if sub, ok := p.subscribers[sender]; ok && sub.Muted {
-		return errors.New("you have been muted")
+	if err := p.sendableFree(sender); err != nil {
+		return err
 	}
 	for c := range p.subscribers {
 		// 不向发送者发送消息
@@ -198,6 +238,93 @@ func (p *Publisher) Publish(v []byte, sender *wes.Connection) error {
 	return nil
 }
 
+// 无锁检查频道是否关闭、发送者是否被禁言及是否有发布权限
+func (p *Publisher) sendableFree(sender *wes.Connection) error {
+	if p.closed {
+		return errors.New("publish forbidden")
+	}
+	if sub, ok := p.subscribers[sender]; ok && sub.Muted && p.mutedFree(sender.UserUuid) {
+		return errors.New("you have been muted")
+	}
+	return p.checkPublish(sender)
+}
+
+// 无锁判断用户是否被禁言
+func (p *Publisher) mutedFree(userUuid string) bool {
+	info, ok := p.mutes[userUuid]
+	return ok && !info.expired()
+}
+
+// 设置用户所有订阅的禁言状态，需在锁内调用
+func (p *Publisher) setMuted(userUuid string, muted bool) {
+	for c, sub := range p.subscribers {
+		if c.UserUuid == userUuid {
+			sub.Muted = muted
+		}
+	}
+}
+
+// Mute 禁言用户，d为0时永久禁言，operator为操作者用户uuid
+// 禁言不持久化，服务重启或动态频道删除后失效
+func (p *Publisher) Mute(userUuid string, d time.Duration, reason string, operator string) MuteInfo {
+	info := MuteInfo{Channel: p.Name, UserUuid: userUuid, Reason: reason, Operator: operator}
+	if d > 0 {
+		info.Until = time.Now().Add(d).UnixMilli()
+	}
+	p.lock.Lock()
+	p.mutes[userUuid] = info
+	p.setMuted(userUuid, true)
+	p.lock.Unlock()
+	loguru.SimpleLog(loguru.Info, "WS", fmt.Sprintf("user %s muted on channel %s by %s", userUuid, p.Name, operator))
+	p.notice(userUuid, "mute", info)
+	return info
+}
+
+// Unmute 解除禁言，用户未被禁言时返回false
+func (p *Publisher) Unmute(userUuid string) bool {
+	p.lock.Lock()
+	muted := p.mutedFree(userUuid)
+	delete(p.mutes, userUuid)
+	p.setMuted(userUuid, false)
+	p.lock.Unlock()
+	if muted {
+		p.notice(userUuid, "unmute", MuteInfo{Channel: p.Name, UserUuid: userUuid})
+	}
+	return muted
+}
+
+// Mutes 所有生效中的禁言
+func (p *Publisher) Mutes() []MuteInfo {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	resp := make([]MuteInfo, 0, len(p.mutes))
+	for userUuid, info := range p.mutes {
+		if info.expired() {
+			delete(p.mutes, userUuid)
+			continue
+		}
+		resp = append(resp, info)
+	}
+	return resp
+}
+
+// 向用户所有会话发送频道通知
+func (p *Publisher) notice(userUuid string, type_ string, v interface{}) {
+	for _, conn := range wes.ConnManager.GetByUser(userUuid) {
+		p.noticeConn(conn, type_, v)
+	}
+}
+
+// 向连接发送频道通知
+func (p *Publisher) noticeConn(conn *wes.Connection, type_ string, v interface{}) {
+	_ = conn.Push(wes.Resp{
+		Id:         "publish." + p.Name,
+		Method:     "publish.channel.notice." + type_,
+		StatusCode: dataType.Success,
+		Data:       v,
+	}, false)
+}
+
 func (p *Publisher) Shutdown() error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -0,0 +1,60 @@
+package subscribe
+
+import (
+	"context"
+	"ginWeb/model/systemMode"
+	"ginWeb/service/wes"
+	"ginWeb/service/wes/moderate"
+	"strconv"
+)
+
+// 按房间消息记录中的id查找被举报的消息，举报者需在房间内
+func resolveRoomMessage(_ context.Context, reporter *wes.Connection, target string, messageId string) (*moderate.Snapshot, error) {
+	id, err := strconv.ParseInt(messageId, 10, 64)
+	if err != nil {
+		return nil, moderate.ErrNotFound
+	}
+	room_, ok := Roomer.Get(target)
+	if !ok {
+		return nil, moderate.ErrNotFound
+	}
+	// 成员可通过room.history获取全部记录，均视为接收者
+	if !room_.IsSuber(reporter) {
+		return nil, moderate.ErrNotRecipient
+	}
+	record, ok := room_.History().Get(id)
+	if !ok {
+		return nil, moderate.ErrNotFound
+	}
+	// 消息记录中为发送者的连接uuid，按用户id查找用户uuid
+	senderUuid := record.SenderUuid
+	if user, err := systemMode.GetUserByID(record.SenderId); err == nil {
+		senderUuid = user.Uuid
+	}
+	return &moderate.Snapshot{
+		MessageId:  messageId,
+		Scope:      "room:" + room_.uuid,
+		SenderUuid: senderUuid,
+		SenderName: record.SenderName,
+		Content:    record.Data,
+		Timestamp:  record.Timestamp,
+	}, nil
+}
+
+// 频道消息不持久化，按最近消息的引用id查找，举报者需订阅该频道
+func resolveChannelMessage(_ context.Context, reporter *wes.Connection, target string, messageId string) (*moderate.Snapshot, error) {
+	snap, ok := moderate.Moderation.Recent(messageId)
+	if !ok || snap.Scope != "channel:"+target {
+		return nil, moderate.ErrNotFound
+	}
+	pub, ok := Publishers.GetPub(target)
+	if !ok || !pub.IsSuber(reporter) {
+		return nil, moderate.ErrNotRecipient
+	}
+	return snap, nil
+}
+
+func init() {
+	moderate.Moderation.Resolve("room", resolveRoomMessage)
+	moderate.Moderation.Resolve("channel", resolveChannelMessage)
+}
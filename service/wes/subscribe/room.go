@@ -8,6 +8,7 @@ import (
 	"ginWeb/model/systemMode"
 	"ginWeb/service/dataType"
 	"ginWeb/service/wes"
+	"ginWeb/service/wes/moderate"
 	"ginWeb/service/wireguard"
 	"ginWeb/utils/loguru"
 	"sync"
@@ -44,6 +45,7 @@ type RoomInfo struct {
 	MaxMember    int    `json:"memberMax"`
 	WithPassword bool   `json:"withPassword"`
 	Forbidden    bool   `json:"forbidden"`
+	Waiting      bool   `json:"waiting"` // 重启恢复后等待成员重连
 }
 
 // notice通知返回的成员地址变动信息
@@ -80,14 +82,15 @@ type RoomConfig struct {
 
 // Roomer 房间管理器单例
 var Roomer = &roomManager{
-	rooms: make(map[string]*room), roomIndex: make([]string, 0), lock: sync.RWMutex{},
+	rooms: make(map[string]*room), roomIndex: make([]string, 0), recovering: make(map[string]string), lock: sync.RWMutex{},
 }
 
 // roomManager 房间管理类
 type roomManager struct {
-	rooms     map[string]*room
-	roomIndex []string // 排序器
-	lock      sync.RWMutex
+	rooms      map[string]*room
+	roomIndex  []string          // 排序器
+	recovering map[string]string // 重启恢复后等待重连的用户uuid和房间uuid的映射
+	lock       sync.RWMutex
 }
 
 // 创建时传入owner信息
@@ -97,27 +100,26 @@ func (r *roomManager) NewRoom(owner *wes.Connection, config *RoomConfig, args ..
 		uuid:      roomName,
 		Link:      uuid.NewString(),
 		subs:      make(map[*wes.Connection]mateAttr),
-		ownerConn: owner,
 		lock:      sync.RWMutex{},
 		Config:    config,
 		forbidden: true,
+		bans:      make(map[string]struct{}),
+		waiting:   make(map[string]mateRecord),
+		history:   newMessageLog(roomName),
 	}
+	newRoom.setOwner(owner)
 	connVlan, err := wireguard.WireguardManager.AddPeer(owner.Uuid, args[0].(string), newRoom.UpdateTrueAddr)
 	if err != nil {
 		return nil, err
 	}
-	newRoom.subs[owner] = mateAttr{Vlan: connVlan, PublicKey: args[0].(string), UdpPort: args[1].(int)}
+	attr := mateAttr{Vlan: connVlan, PublicKey: args[0].(string), UdpPort: args[1].(int)}
+	newRoom.subs[owner] = attr
 	// 将退出房间添加到ws连接关闭钩子中，主动退出房间将会删除该钩子
-	owner.DoneHook("publish.room."+newRoom.uuid, func() {
-		wireguard.WireguardManager.RemovePeer(owner.Uuid)
-		newRoom.lock.Lock()
-		defer newRoom.lock.Unlock()
-		loguru.SimpleLog(loguru.Debug, "WS ROOM", fmt.Sprintf("user %d force to exit room %s by done hook",
-			owner.UserId, newRoom.uuid))
-		// 最后执行删除，防止房间关闭导致空指针访问
-		newRoom.deleteMember(owner)
-	})
+	owner.DoneHook("publish.room."+newRoom.uuid, newRoom.exitHook(owner))
 	_ = r.Set(roomName, newRoom)
+	newRoom.saveState()
+	newRoom.saveMember(owner, attr)
+	Presences.setRoom(owner.UserUuid, roomName, newRoom.publicFree())
 
 	loguru.SimpleLog(loguru.Info, "WS ROOM", fmt.Sprintf("room created by user %s id %d, room uuid %s", owner.UserName, owner.UserId, roomName))
 	_ = newRoom.Start("")
@@ -145,6 +147,23 @@ func (r *roomManager) Get(roomUidOrLink string) (*room, bool) {
 	return nil, false
 }
 
+// RoomOf 连接所在的房间
+func (r *roomManager) RoomOf(c *wes.Connection) (*room, bool) {
+	// 复制后释放锁再检查，房间关闭时会在房间锁内获取管理器锁
+	r.lock.RLock()
+	rooms := make([]*room, 0, len(r.rooms))
+	for _, room := range r.rooms {
+		rooms = append(rooms, room)
+	}
+	r.lock.RUnlock()
+	for _, room := range rooms {
+		if room.IsSuber(c) {
+			return room, true
+		}
+	}
+	return nil, false
+}
+
 func (r *roomManager) removeIndex(key string) {
 	for i, v := range r.roomIndex {
 		if v == key {
@@ -178,27 +197,32 @@ func (r *roomManager) Del(roomUid string) {
 }
 
 func (r *roomManager) List(page int, size int) []RoomInfo {
-	r.lock.RLock()
-	defer r.lock.RUnlock()
 	infos := make([]RoomInfo, 0)
+	// 复制后释放锁再读取房间信息，房间关闭时会在房间锁内获取管理器锁
+	r.lock.RLock()
 	if len(r.roomIndex) == 0 {
+		r.lock.RUnlock()
 		return infos
 	}
 	start := (page - 1) * size
 	end := start + size
 	// 起点超限返回空
 	if start >= len(r.roomIndex) {
+		r.lock.RUnlock()
 		return infos
 	}
 	// 终点超限截断
 	if end > len(r.roomIndex) {
 		end = len(r.roomIndex)
 	}
+	rooms := make([]*room, 0, end-start)
 	for _, key := range r.roomIndex[start:end] {
-		room_, ok := r.rooms[key]
-		if !ok {
-			continue
+		if room_, ok := r.rooms[key]; ok {
+			rooms = append(rooms, room_)
 		}
+	}
+	r.lock.RUnlock()
+	for _, room_ := range rooms {
 		infos = append(infos, room_.Info())
 	}
 	return infos
@@ -212,9 +236,17 @@ type room struct {
 	uuid      string                       // id
 	subs      map[*wes.Connection]mateAttr // 成员ws连接对象
 	lock      sync.RWMutex                 // 对象读写锁
-	ownerConn *wes.Connection              // 房间持有者
+	ownerConn *wes.Connection              // 房间持有者，恢复后房主未重连时为nil
+	ownerUuid string                       // 房间持有者用户uuid
+	ownerId   int64                        // 房间持有者用户id
+	ownerName string                       // 房间持有者用户名
 	Link      string                       // 无视关闭状态和密码的进房链接
 	Config    *RoomConfig                  `json:"config"` //房间设置
+	bans      map[string]struct{}          // 被封禁的用户uuid，不可再进入
+
+	waiting      map[string]mateRecord // 重启恢复后等待重连的成员
+	recoverTimer *time.Timer           // 等待重连的计时器
+	history      *messageLog           // 消息记录
 
 	refreshCtx context.Context // 房间生命周期刷新上下文
 	refresh    context.CancelFunc
@@ -231,12 +263,30 @@ func (r *room) Info() RoomInfo {
 		RoomID:       r.uuid,
 		RoomTitle:    r.Config.Title,
 		Description:  r.Config.Description,
-		OwnerID:      r.ownerConn.UserId,
-		OwnerName:    r.ownerConn.UserName,
+		OwnerID:      r.ownerId,
+		OwnerName:    r.ownerName,
 		MemberCount:  len(r.subs),
 		MaxMember:    r.Config.MaxMember,
-		WithPassword: r.Config.Password != nil && *r.Config.Password != "",
+		WithPassword: r.withPasswordFree(),
 		Forbidden:    r.forbidden,
+		Waiting:      len(r.waiting) > 0,
+	}
+}
+
+// 无锁判断房间是否公开，未关闭入口且无密码的房间为公开房间
+func (r *room) publicFree() bool {
+	return !r.forbidden && !r.withPasswordFree()
+}
+
+// 无锁判断房间是否设置了密码
+func (r *room) withPasswordFree() bool {
+	return r.Config.Password != nil && *r.Config.Password != ""
+}
+
+// 房间公开状态变化后更新成员的在线状态，需在锁内调用
+func (r *room) refreshPresenceFree() {
+	for c := range r.subs {
+		Presences.setRoom(c.UserUuid, r.uuid, r.publicFree())
 	}
 }
 
@@ -249,7 +299,22 @@ func (r *room) UUID() string {
 func (r *room) OwnerUuid() string {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
-	return r.ownerConn.UserUuid
+	return r.ownerUuid
+}
+
+// Public 房间是否公开，公开房间无需链接即可进入
+func (r *room) Public() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.publicFree()
+}
+
+// 设置房主，需在锁内调用
+func (r *room) setOwner(c *wes.Connection) {
+	r.ownerConn = c
+	r.ownerUuid = c.UserUuid
+	r.ownerId = c.UserId
+	r.ownerName = c.UserName
 }
 
 func (r *room) IsSuber(c *wes.Connection) bool {
@@ -265,23 +330,91 @@ func (r *room) KickMember(c *wes.Connection, targetUuid string) error {
 	if c != r.ownerConn {
 		return errors.New("only owner can kick members")
 	}
+	if !r.kickFree(targetUuid) {
+		return errors.New("member not found")
+	}
+	return nil
+}
+
+// BanMember 封禁用户，封禁后不可再进入房间，用户在房间内时同时踢出
+func (r *room) BanMember(c *wes.Connection, targetUuid string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if c != r.ownerConn {
+		return errors.New("only owner can ban members")
+	}
+	if targetUuid == r.ownerUuid {
+		return errors.New("can not ban owner")
+	}
+	if _, ok := r.bans[targetUuid]; !ok {
+		r.bans[targetUuid] = struct{}{}
+		r.saveBan(targetUuid)
+	}
+	r.kickFree(targetUuid)
+	return nil
+}
+
+// UnbanMember 解除封禁
+func (r *room) UnbanMember(c *wes.Connection, targetUuid string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if c != r.ownerConn {
+		return errors.New("only owner can unban members")
+	}
+	if _, ok := r.bans[targetUuid]; !ok {
+		return errors.New("user not banned")
+	}
+	delete(r.bans, targetUuid)
+	r.removeBan(targetUuid)
+	return nil
+}
+
+// Bans 封禁名单中的用户uuid
+func (r *room) Bans() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	list := make([]string, 0, len(r.bans))
+	for target := range r.bans {
+		list = append(list, target)
+	}
+	return list
+}
+
+// 无锁踢出用户的所有会话，用户不在房间内时返回false
+func (r *room) kickFree(targetUuid string) bool {
+	conns := make([]*wes.Connection, 0)
 	for conn := range r.subs {
 		if conn.UserUuid == targetUuid {
+			conns = append(conns, conn)
 			wireguard.WireguardManager.RemovePeer(conn.Uuid)
-			go func() {
-				r.Notice(targetUuid, "kick", nil)
-				r.deleteMember(conn)
-			}()
-			return nil
 		}
 	}
-	return errors.New("member not found")
+	if len(conns) == 0 {
+		return false
+	}
+	go func() {
+		r.Notice(targetUuid, "kick", nil)
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		for _, conn := range conns {
+			if _, ok := r.subs[conn]; ok {
+				r.deleteMember(conn)
+				conn.DeleteDoneHook("publish.room." + r.uuid)
+			}
+		}
+	}()
+	return true
 }
 
 // Mates 所有成员
 func (r *room) Mates() []MateInfo {
 	r.lock.RLock()
 	defer r.lock.RUnlock()
+	return r.mates()
+}
+
+// 无锁获取成员
+func (r *room) mates() []MateInfo {
 	resp := make([]MateInfo, 0)
 	for c, attr := range r.subs {
 		resp = append(resp, MateInfo{
@@ -360,8 +493,11 @@ func (r *room) Subscribe(c *wes.Connection, args ...any) error {
 	if r.Config.MaxMember != 0 && len(r.subs) >= r.Config.MaxMember {
 		return errors.New("room is full")
 	}
+	if _, ok := r.bans[c.UserUuid]; ok {
+		return errors.New("you have been banned from this room")
+	}
 	if r.Config.UserIdBlackList {
-		exist, err := systemMode.ExistInList(r.ownerConn.Uuid, c.UserUuid)
+		exist, err := systemMode.ExistInList(r.ownerUuid, c.UserUuid)
 		if err != nil {
 			return err
 		}
@@ -370,7 +506,7 @@ func (r *room) Subscribe(c *wes.Connection, args ...any) error {
 		}
 	}
 	if r.Config.IPBlackList {
-		exist, err := systemMode.ExistInList(r.ownerConn.Uuid, c.IP)
+		exist, err := systemMode.ExistInList(r.ownerUuid, c.IP)
 		if err != nil {
 			return err
 		}
@@ -379,7 +515,7 @@ func (r *room) Subscribe(c *wes.Connection, args ...any) error {
 		}
 	}
 	if r.Config.DeviceBlackList {
-		exist, err := systemMode.ExistInList(r.ownerConn.Uuid, c.MacAddress)
+		exist, err := systemMode.ExistInList(r.ownerUuid, c.MacAddress)
 		if err != nil {
 			return err
 		}
@@ -391,18 +527,13 @@ func (r *room) Subscribe(c *wes.Connection, args ...any) error {
 	if err != nil {
 		return err
 	}
-	r.subs[c] = mateAttr{Vlan: connVlan, UdpPort: args[1].(int), PublicKey: args[0].(string)}
+	attr := mateAttr{Vlan: connVlan, UdpPort: args[1].(int), PublicKey: args[0].(string)}
+	r.subs[c] = attr
+	r.saveMember(c, attr)
+	Presences.setRoom(c.UserUuid, r.uuid, r.publicFree())
 	loguru.SimpleLog(loguru.Info, "WS ROOM", fmt.Sprintf("user %d get in room %s", c.UserId, r.uuid))
 	// 将退出房间添加到ws连接关闭钩子中，主动退出房间将会删除该钩子
-	c.DoneHook("publish.room."+r.uuid, func() {
-		wireguard.WireguardManager.RemovePeer(c.Uuid)
-		r.lock.Lock()
-		defer r.lock.Unlock()
-		loguru.SimpleLog(loguru.Debug, "WS ROOM", fmt.Sprintf("user %d force to exit room %s by done hook",
-			c.UserId, r.uuid))
-		// 最后执行删除，防止房间关闭导致空指针访问
-		r.deleteMember(c)
-	})
+	c.DoneHook("publish.room."+r.uuid, r.exitHook(c))
 	go r.Notice(MateInfo{
 		Id:        int(c.UserId),
 		Name:      c.UserName,
@@ -416,27 +547,47 @@ func (r *room) Subscribe(c *wes.Connection, args ...any) error {
 	return nil
 }
 
+// 连接断开时退出房间的钩子函数
+func (r *room) exitHook(c *wes.Connection) func() {
+	return func() {
+		wireguard.WireguardManager.RemovePeer(c.Uuid)
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		loguru.SimpleLog(loguru.Debug, "WS ROOM", fmt.Sprintf("user %d force to exit room %s by done hook",
+			c.UserId, r.uuid))
+		// 最后执行删除，防止房间关闭导致空指针访问
+		r.deleteMember(c)
+	}
+}
+
 // 删除成员并检测房间成员数量和房主转移
 func (r *room) deleteMember(c *wes.Connection) {
 	delete(r.subs, c)
-	// 全部退出后关闭room
-	if len(r.subs) == 0 {
+	r.removeMember(c.UserUuid)
+	Presences.leaveRoom(c.UserUuid, r.uuid)
+	// 全部退出且无等待重连的成员后关闭room
+	if len(r.subs) == 0 && len(r.waiting) == 0 {
 		r.shutdownFree()
 	}
 	go r.Notice(c.UserUuid, "out", c)
 	if c == r.ownerConn {
-		// 推举下一个房主
-		for ele := range r.subs {
-			r.ownerConn = ele
-			go func() {
-				type temp struct {
-					Old string `json:"old"`
-					New string `json:"new"`
-				}
-				r.Notice(temp{Old: c.UserUuid, New: r.ownerConn.UserUuid}, "exchangeOwner", nil)
-			}()
-			break
-		}
+		r.electOwner(c.UserUuid)
+	}
+}
+
+// 推举下一个房主，old为原房主用户uuid
+func (r *room) electOwner(old string) {
+	for ele := range r.subs {
+		r.setOwner(ele)
+		r.saveState()
+		go func() {
+			type temp struct {
+				Old string `json:"old"`
+				New string `json:"new"`
+			}
+			r.Notice(temp{Old: old, New: ele.UserUuid}, "exchangeOwner", nil)
+		}()
+		break
 	}
 }
 
@@ -461,9 +612,18 @@ func (r *room) Forbidden(to bool) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 	r.forbidden = to
+	r.saveState()
+	r.refreshPresenceFree()
 	go r.Notice(to, "forbidden", nil)
 }
 
+// 需要客户端确认的房间通知类型，未确认时重试并在恢复会话时重放
+var criticalNotices = map[string]struct{}{
+	"kick":               {},
+	"exchangeOwner":      {},
+	"updatePeerEndpoint": {},
+}
+
 // Notice 发送系统通知，sender为通知触发者，不会收到消息，不会显式出现在报文中
 func (r *room) Notice(v interface{}, type_ string, sender *wes.Connection) {
 	note := "publish.room.notice"
@@ -476,32 +636,45 @@ func (r *room) Notice(v interface{}, type_ string, sender *wes.Connection) {
 		StatusCode: dataType.Success,
 		Data:       v,
 	}
-	data, _ := json.Marshal(res)
-	err := r.Publish(data, sender)
-	if err != nil {
-		loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("room notice of %s err: %v", type_, err))
+	_, critical := criticalNotices[type_]
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.Config.AutoClose {
+		r.refresh()
+	}
+	for c := range r.subs {
+		if c == sender {
+			continue
+		}
+		if err := c.Push(res, critical); err != nil {
+			loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("room notice of %s to %s err: %v", type_, c.UserUuid, err))
+		}
 	}
 }
 
-// Message 房间内发送消息
-func (r *room) Message(msg string, sender *wes.Connection) {
+// Message 房间内发送消息，消息需通过审核，返回消息id
+func (r *room) Message(msg string, sender *wes.Connection) (int64, error) {
+	text, err := moderate.Moderation.Check("room:"+r.uuid, sender, msg)
+	if err != nil {
+		return 0, err
+	}
+	record := r.history.append(sender, text)
 	var res = wes.Resp{
 		Id:         r.uuid,
 		Method:     "publish.room.message",
 		StatusCode: dataType.Success,
-		Data: publisherResp{
-			SenderId:   sender.UserId,
-			SenderName: sender.UserName,
-			SenderUuid: sender.Uuid,
-			Timestamp:  time.Now().UnixMilli(),
-			Data:       msg,
-		},
+		Data:       record,
 	}
 	data, _ := json.Marshal(res)
 	go func() {
 		_ = r.Publish(data, sender)
 	}()
+	return record.MessageId, nil
+}
 
+// History 房间消息记录
+func (r *room) History() *messageLog {
+	return r.history
 }
 
 // Publish 向所有成员广播消息，提供sender后不向sender发送
@@ -548,7 +721,11 @@ func (r *room) Start(...interface{}) error {
 // 无锁关闭room，包内防止死锁
 func (r *room) shutdownFree() {
 	clear(r.subs)
+	users := r.releaseWaiting()
+	r.dropState()
+	r.history.drop()
 	loguru.SimpleLog(loguru.Info, "WS ROOM", fmt.Sprintf("room uuid %s closed", r.uuid))
+	Roomer.forget(r.uuid, users)
 	Roomer.Del(r.uuid)
 	r.lifetimeEnd()
 }
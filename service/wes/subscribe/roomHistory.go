@@ -0,0 +1,207 @@
+package subscribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ginWeb/config"
+	"ginWeb/service/wes"
+	"ginWeb/utils/database"
+	"ginWeb/utils/loguru"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 每个房间保留的消息记录数量
+var historySize = config.Conf.Server.Room.HistorySize
+
+// 消息记录是否保存至redis
+var historyPersist = config.Conf.Server.Room.HistoryPersist
+
+// HistoryEnabled 是否开启房间消息记录
+func HistoryEnabled() bool {
+	return historySize > 0
+}
+
+// 单次查询的最大记录数
+const historyMaxLimit = 100
+
+// RoomMessage 房间消息，id在房间内单调递增
+type RoomMessage struct {
+	MessageId  int64  `json:"messageId"` // 举报时使用的消息id
+	SenderId   int64  `json:"senderId"`
+	SenderName string `json:"senderName"`
+	SenderUuid string `json:"senderUuid"`
+	Timestamp  int64  `json:"timestamp"`
+	Data       string `json:"data"`
+}
+
+// 房间消息记录，按id升序保存最近的historySize条消息
+type messageLog struct {
+	roomUuid string
+	lock     sync.RWMutex
+	lastId   int64
+	msgs     []RoomMessage
+}
+
+func newMessageLog(roomUuid string) *messageLog {
+	return &messageLog{roomUuid: roomUuid, msgs: make([]RoomMessage, 0)}
+}
+
+func historyKey(roomUuid string) string {
+	return fmt.Sprintf("::roomHistory::%s", roomUuid)
+}
+
+func historyIdKey(roomUuid string) string {
+	return fmt.Sprintf("::roomHistoryId::%s", roomUuid)
+}
+
+// 生成消息id并记录消息
+func (m *messageLog) append(sender *wes.Connection, msg string) RoomMessage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.lastId = m.nextId()
+	record := RoomMessage{
+		MessageId:  m.lastId,
+		SenderId:   sender.UserId,
+		SenderName: sender.UserName,
+		SenderUuid: sender.Uuid,
+		Timestamp:  time.Now().UnixMilli(),
+		Data:       msg,
+	}
+	if historySize <= 0 {
+		return record
+	}
+	m.msgs = append(m.msgs, record)
+	if len(m.msgs) > historySize {
+		m.msgs = m.msgs[len(m.msgs)-historySize:]
+	}
+	if historyPersist {
+		m.persist(record)
+	}
+	return record
+}
+
+// 持久化时使用redis自增id，保证重启后id不回退
+func (m *messageLog) nextId() int64 {
+	if !historyPersist || historySize <= 0 {
+		return m.lastId + 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	id, err := database.Rdb.Incr(ctx, historyIdKey(m.roomUuid)).Result()
+	if err != nil || id <= m.lastId {
+		return m.lastId + 1
+	}
+	return id
+}
+
+func (m *messageLog) persist(record RoomMessage) {
+	data, _ := json.Marshal(record)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	key := historyKey(m.roomUuid)
+	_, err := database.Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, data)
+		pipe.LTrim(ctx, key, int64(-historySize), -1)
+		return nil
+	})
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("save history of room %s failed: %s", m.roomUuid, err.Error()))
+	}
+}
+
+// 从redis中加载消息记录
+func (m *messageLog) load() {
+	if !historyPersist || historySize <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	values, err := database.Rdb.LRange(ctx, historyKey(m.roomUuid), int64(-historySize), -1).Result()
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("load history of room %s failed: %s", m.roomUuid, err.Error()))
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, v := range values {
+		var record RoomMessage
+		if json.Unmarshal([]byte(v), &record) != nil {
+			continue
+		}
+		m.msgs = append(m.msgs, record)
+		if record.MessageId > m.lastId {
+			m.lastId = record.MessageId
+		}
+	}
+}
+
+// 删除持久化的消息记录
+func (m *messageLog) drop() {
+	if !historyPersist {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	database.Rdb.Del(ctx, historyKey(m.roomUuid), historyIdKey(m.roomUuid))
+}
+
+// Before 获取id小于beforeId的最近limit条消息，beforeId不大于0时从最新消息开始
+func (m *messageLog) Before(beforeId int64, limit int) []RoomMessage {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	end := len(m.msgs)
+	if beforeId > 0 {
+		end = sort.Search(len(m.msgs), func(i int) bool { return m.msgs[i].MessageId >= beforeId })
+	}
+	start := end - clampLimit(limit)
+	if start < 0 {
+		start = 0
+	}
+	resp := make([]RoomMessage, end-start)
+	copy(resp, m.msgs[start:end])
+	return resp
+}
+
+// After 获取id大于afterId的最早limit条消息，用于断线后续传
+func (m *messageLog) After(afterId int64, limit int) []RoomMessage {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	start := sort.Search(len(m.msgs), func(i int) bool { return m.msgs[i].MessageId > afterId })
+	end := start + clampLimit(limit)
+	if end > len(m.msgs) {
+		end = len(m.msgs)
+	}
+	resp := make([]RoomMessage, end-start)
+	copy(resp, m.msgs[start:end])
+	return resp
+}
+
+// Get 按id获取记录中的消息
+func (m *messageLog) Get(messageId int64) (RoomMessage, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	i := sort.Search(len(m.msgs), func(i int) bool { return m.msgs[i].MessageId >= messageId })
+	if i == len(m.msgs) || m.msgs[i].MessageId != messageId {
+		return RoomMessage{}, false
+	}
+	return m.msgs[i], true
+}
+
+// LastId 最新的消息id
+func (m *messageLog) LastId() int64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.lastId
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 || limit > historyMaxLimit {
+		return historyMaxLimit
+	}
+	return limit
+}
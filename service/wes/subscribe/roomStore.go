@@ -0,0 +1,410 @@
+package subscribe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ginWeb/config"
+	"ginWeb/model/roomMode"
+	"ginWeb/service/dataType"
+	"ginWeb/service/wes"
+	"ginWeb/service/wireguard"
+	"ginWeb/utils/database"
+	"ginWeb/utils/loguru"
+	"sync"
+	"time"
+)
+
+// 房间已失效，恢复失败时删除持久化数据
+var errRoomDead = errors.New("room is dead")
+
+// 房间是否持久化
+var roomPersist = config.Conf.Server.Room.Persist
+
+// 重启后成员重连的宽限时间
+var recoverGrace = time.Duration(config.Conf.Server.Room.RecoverGrace) * time.Second
+
+// 重连恢复的钩子函数名称
+const recoverHookName = "room.recover"
+
+// 持久化的成员信息，重启后用于恢复成员vlan地址
+type mateRecord struct {
+	UserUuid  string `json:"userUuid"`
+	UserId    int64  `json:"userId"`
+	UserName  string `json:"userName"`
+	Vlan      uint16 `json:"vlan"`
+	PublicKey string `json:"publicKey"`
+	UdpPort   int    `json:"udpPort"`
+}
+
+// NoticeRecover 重连后自动回到房间的通知信息
+type NoticeRecover struct {
+	RoomId string     `json:"roomId"`
+	Link   string     `json:"link,omitempty"` // 仅房主可见
+	Mates  []MateInfo `json:"mates"`
+}
+
+// 持久化写入队列，房间锁内按顺序提交，在单独的协程中写入，存储缓慢时不阻塞房间
+var roomWrites = &writeQueue{wake: make(chan struct{}, 1)}
+
+type writeQueue struct {
+	lock  sync.Mutex
+	tasks []func()
+	wake  chan struct{}
+}
+
+// 提交写入任务
+func (q *writeQueue) push(f func()) {
+	q.lock.Lock()
+	q.tasks = append(q.tasks, f)
+	q.lock.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 按提交顺序执行写入任务
+func (q *writeQueue) run() {
+	for range q.wake {
+		for {
+			q.lock.Lock()
+			tasks := q.tasks
+			q.tasks = nil
+			q.lock.Unlock()
+			if len(tasks) == 0 {
+				break
+			}
+			for _, f := range tasks {
+				f()
+			}
+		}
+	}
+}
+
+func memberKey(roomUuid string) string {
+	return fmt.Sprintf("::roomMember::%s", roomUuid)
+}
+
+// 保存房间信息，需在锁内调用，锁内生成快照后异步写入
+func (r *room) saveState() {
+	if !roomPersist {
+		return
+	}
+	conf, _ := json.Marshal(r.Config)
+	row := roomMode.Room{
+		Uuid:      r.uuid,
+		Link:      r.Link,
+		OwnerUuid: r.ownerUuid,
+		OwnerId:   r.ownerId,
+		OwnerName: r.ownerName,
+		Config:    string(conf),
+		Forbidden: r.forbidden,
+	}
+	roomWrites.push(func() {
+		if err := row.Save(); err != nil {
+			loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("save room %s failed: %s", row.Uuid, err.Error()))
+		}
+	})
+}
+
+// 删除房间持久化信息，需在锁内调用，异步写入
+func (r *room) dropState() {
+	if !roomPersist {
+		return
+	}
+	roomUuid := r.uuid
+	roomWrites.push(func() {
+		if err := roomMode.CloseRoom(roomUuid); err != nil {
+			loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("close room %s failed: %s", roomUuid, err.Error()))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		database.Rdb.Del(ctx, memberKey(roomUuid))
+	})
+}
+
+// 保存成员信息，需在锁内调用，异步写入
+func (r *room) saveMember(c *wes.Connection, attr mateAttr) {
+	if !roomPersist {
+		return
+	}
+	data, _ := json.Marshal(mateRecord{
+		UserUuid:  c.UserUuid,
+		UserId:    c.UserId,
+		UserName:  c.UserName,
+		Vlan:      attr.Vlan,
+		PublicKey: attr.PublicKey,
+		UdpPort:   attr.UdpPort,
+	})
+	roomUuid, userUuid := r.uuid, c.UserUuid
+	roomWrites.push(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if err := database.Rdb.HSet(ctx, memberKey(roomUuid), userUuid, data).Err(); err != nil {
+			loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("save member of room %s failed: %s", roomUuid, err.Error()))
+		}
+	})
+}
+
+// 删除成员信息，需在锁内调用，异步写入
+func (r *room) removeMember(userUuid string) {
+	if !roomPersist {
+		return
+	}
+	roomUuid := r.uuid
+	roomWrites.push(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		database.Rdb.HDel(ctx, memberKey(roomUuid), userUuid)
+	})
+}
+
+// 保存封禁名单，需在锁内调用，异步写入
+func (r *room) saveBan(targetUuid string) {
+	if !roomPersist {
+		return
+	}
+	ban := roomMode.RoomBan{RoomUuid: r.uuid, TargetUuid: targetUuid}
+	roomWrites.push(func() {
+		if err := ban.Add(); err != nil {
+			loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("save ban of room %s failed: %s", ban.RoomUuid, err.Error()))
+		}
+	})
+}
+
+// 删除封禁名单，需在锁内调用，异步写入
+func (r *room) removeBan(targetUuid string) {
+	if !roomPersist {
+		return
+	}
+	ban := roomMode.RoomBan{RoomUuid: r.uuid, TargetUuid: targetUuid}
+	roomWrites.push(func() {
+		if err := ban.Remove(); err != nil {
+			loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("remove ban of room %s failed: %s", ban.RoomUuid, err.Error()))
+		}
+	})
+}
+
+// Recover 从持久化数据中恢复房间，恢复的房间等待成员重连，需在wireguard启动后调用
+func (r *roomManager) Recover() {
+	if !roomPersist {
+		return
+	}
+	rows, err := roomMode.AliveRooms()
+	if err != nil {
+		loguru.SimpleLog(loguru.Error, "WS ROOM", "load rooms failed: "+err.Error())
+		return
+	}
+	for _, row := range rows {
+		newRoom, err := r.recoverRoom(row)
+		if errors.Is(err, errRoomDead) {
+			loguru.SimpleLog(loguru.Warn, "WS ROOM", fmt.Sprintf("drop room %s: %s", row.Uuid, err.Error()))
+			newRoom.releaseReserved()
+			newRoom.dropState()
+			newRoom.history.drop()
+			continue
+		}
+		if err != nil {
+			// 读取失败时保留持久化数据，房间仍可能存活
+			loguru.SimpleLog(loguru.Error, "WS ROOM", fmt.Sprintf("skip room %s: %s", row.Uuid, err.Error()))
+			newRoom.releaseReserved()
+			continue
+		}
+		r.lock.Lock()
+		for userUuid := range newRoom.waiting {
+			r.recovering[userUuid] = newRoom.uuid
+		}
+		r.lock.Unlock()
+		_ = r.Set(newRoom.uuid, newRoom)
+		_ = newRoom.Start("")
+		newRoom.recoverTimer = time.AfterFunc(recoverGrace, newRoom.endRecover)
+		loguru.SimpleLog(loguru.Info, "WS ROOM", fmt.Sprintf("room %s recovered, waiting %d members",
+			newRoom.uuid, len(newRoom.waiting)))
+	}
+}
+
+func (r *roomManager) recoverRoom(row roomMode.Room) (*room, error) {
+	newRoom := &room{
+		uuid:      row.Uuid,
+		Link:      row.Link,
+		subs:      make(map[*wes.Connection]mateAttr),
+		ownerUuid: row.OwnerUuid,
+		ownerId:   row.OwnerId,
+		ownerName: row.OwnerName,
+		Config:    &RoomConfig{},
+		forbidden: row.Forbidden,
+		bans:      make(map[string]struct{}),
+		waiting:   make(map[string]mateRecord),
+		history:   newMessageLog(row.Uuid),
+	}
+	if err := json.Unmarshal([]byte(row.Config), newRoom.Config); err != nil {
+		return newRoom, fmt.Errorf("%w: %s", errRoomDead, err.Error())
+	}
+	bans, err := roomMode.BansOf(row.Uuid)
+	if err != nil {
+		return newRoom, err
+	}
+	for _, target := range bans {
+		newRoom.bans[target] = struct{}{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	members, err := database.Rdb.HGetAll(ctx, memberKey(row.Uuid)).Result()
+	if err != nil {
+		return newRoom, err
+	}
+	for _, v := range members {
+		var rec mateRecord
+		if json.Unmarshal([]byte(v), &rec) != nil {
+			continue
+		}
+		if !wireguard.WireguardManager.ReserveVlan(rec.Vlan) {
+			loguru.SimpleLog(loguru.Warn, "WS ROOM", fmt.Sprintf("vlan %d of user %s conflict", rec.Vlan, rec.UserUuid))
+			continue
+		}
+		newRoom.waiting[rec.UserUuid] = rec
+	}
+	if len(newRoom.waiting) == 0 {
+		return newRoom, fmt.Errorf("%w: no member to recover", errRoomDead)
+	}
+	newRoom.history.load()
+	return newRoom, nil
+}
+
+// 用户重连时重新加入恢复的房间
+func (r *roomManager) readmit(c *wes.Connection) {
+	r.lock.Lock()
+	roomUuid, ok := r.recovering[c.UserUuid]
+	delete(r.recovering, c.UserUuid)
+	r.lock.Unlock()
+	if !ok {
+		return
+	}
+	room_, ok := r.Get(roomUuid)
+	if !ok {
+		return
+	}
+	if err := room_.readmit(c); err != nil {
+		loguru.SimpleLog(loguru.Warn, "WS ROOM", fmt.Sprintf("user %s readmit room %s failed: %s",
+			c.UserUuid, roomUuid, err.Error()))
+	}
+}
+
+// 重连成员使用原vlan地址回到房间
+func (r *room) readmit(c *wes.Connection) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	rec, ok := r.waiting[c.UserUuid]
+	if !ok {
+		return errors.New("not waiting member")
+	}
+	delete(r.waiting, c.UserUuid)
+	connVlan, err := wireguard.WireguardManager.AddReservedPeer(c.Uuid, rec.PublicKey, rec.Vlan, r.UpdateTrueAddr)
+	if err != nil {
+		r.removeMember(c.UserUuid)
+		r.finishRecover()
+		return err
+	}
+	attr := mateAttr{Vlan: connVlan, PublicKey: rec.PublicKey, UdpPort: rec.UdpPort}
+	r.subs[c] = attr
+	if c.UserUuid == r.ownerUuid {
+		r.setOwner(c)
+	}
+	r.saveMember(c, attr)
+	Presences.setRoom(c.UserUuid, r.uuid, r.publicFree())
+	c.DoneHook("publish.room."+r.uuid, r.exitHook(c))
+	loguru.SimpleLog(loguru.Info, "WS ROOM", fmt.Sprintf("user %d readmitted to room %s", c.UserId, r.uuid))
+
+	notice := NoticeRecover{RoomId: r.uuid, Mates: r.mates()}
+	if c == r.ownerConn {
+		notice.Link = r.Link
+	}
+	_ = c.Push(wes.Resp{
+		Id:         r.uuid,
+		Method:     "publish.room.notice.recover",
+		StatusCode: dataType.Success,
+		Data:       notice,
+	}, true)
+	go r.Notice(MateInfo{
+		Id:        int(c.UserId),
+		Name:      c.UserName,
+		Uuid:      c.UserUuid,
+		Owner:     c == r.ownerConn,
+		Vlan:      int(connVlan),
+		PublicKey: rec.PublicKey,
+		UdpPort:   rec.UdpPort,
+	}, "in", c)
+	r.finishRecover()
+	return nil
+}
+
+// 所有成员都已重连时提前结束等待，需在锁内调用
+func (r *room) finishRecover() {
+	if len(r.waiting) != 0 {
+		return
+	}
+	if r.recoverTimer != nil {
+		r.recoverTimer.Stop()
+	}
+	if len(r.subs) == 0 {
+		r.shutdownFree()
+		return
+	}
+	if r.ownerConn == nil {
+		r.electOwner(r.ownerUuid)
+	}
+}
+
+// 宽限时间结束，释放未重连成员的vlan地址
+func (r *room) endRecover() {
+	r.lock.Lock()
+	users := r.releaseWaiting()
+	r.finishRecover()
+	r.lock.Unlock()
+	Roomer.forget(r.uuid, users)
+}
+
+// 释放所有等待重连成员保留的地址，需在锁内调用
+// 返回被释放的用户uuid，由调用方通过Roomer.forget清理
+func (r *room) releaseWaiting() []string {
+	if r.recoverTimer != nil {
+		r.recoverTimer.Stop()
+	}
+	users := make([]string, 0, len(r.waiting))
+	for userUuid, rec := range r.waiting {
+		wireguard.WireguardManager.ReleaseVlan(rec.Vlan)
+		r.removeMember(userUuid)
+		users = append(users, userUuid)
+	}
+	clear(r.waiting)
+	return users
+}
+
+// 恢复失败时释放已保留的vlan地址，不删除持久化数据
+func (r *room) releaseReserved() {
+	for _, rec := range r.waiting {
+		wireguard.WireguardManager.ReleaseVlan(rec.Vlan)
+	}
+	clear(r.waiting)
+}
+
+// 清理不再等待重连的用户，管理器锁内不会获取房间锁，可在房间锁内调用
+func (r *roomManager) forget(roomUuid string, users []string) {
+	if len(users) == 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, userUuid := range users {
+		if r.recovering[userUuid] == roomUuid {
+			delete(r.recovering, userUuid)
+		}
+	}
+}
+
+func init() {
+	go roomWrites.run()
+	wes.ConnManager.ConnectHook(recoverHookName, Roomer.readmit)
+}
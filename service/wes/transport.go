@@ -0,0 +1,63 @@
+package wes
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 传输名称
+const (
+	TransportWebsocket = "websocket"
+	TransportSSE       = "sse"
+)
+
+// 连接的底层传输，会话恢复时替换为新的传输
+type transport interface {
+	// 写入一条消息，由writeLoop独占调用
+	write(messageType int, data []byte) error
+	// 绑定至连接后开始接收数据
+	attach(c *Connection)
+	// 是否需要应用层ping/pong心跳检测
+	heartbeat() bool
+	name() string
+	remoteAddr() string
+	close() error
+}
+
+// websocket传输
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func (t *wsTransport) write(messageType int, data []byte) error {
+	if writeTimeout > 0 {
+		_ = t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	// 小消息压缩收益低，超过阈值才压缩
+	if compression.Enable {
+		t.conn.EnableWriteCompression(len(data) >= compression.Threshold)
+	}
+	return t.conn.WriteMessage(messageType, data)
+}
+
+func (t *wsTransport) attach(c *Connection) {
+	bindHandlers(t.conn, c)
+	go c.listen(t)
+}
+
+func (t *wsTransport) heartbeat() bool {
+	return true
+}
+
+func (t *wsTransport) name() string {
+	return TransportWebsocket
+}
+
+func (t *wsTransport) remoteAddr() string {
+	return t.conn.RemoteAddr().String()
+}
+
+func (t *wsTransport) close() error {
+	return t.conn.Close()
+}
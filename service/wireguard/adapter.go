@@ -37,6 +37,7 @@ type adapterManager struct {
 	peers       map[string]*peer
 	vlanID      uint16
 	vlanRecover chan uint16
+	reserved    map[uint16]struct{} // 为重连成员保留的vlan地址
 }
 
 func (am *adapterManager) PeersCount() int {
@@ -65,22 +66,76 @@ func (am *adapterManager) Start() (err error) {
 	return server.Device.Up()
 }
 
-// 分配局域网IPV4后两段，最多支持65535-1个局域网IP
+// 分配局域网IPV4后两段，最多支持65535-1个局域网IP，跳过已保留的地址
 func (am *adapterManager) vlan() (v uint16, err error) {
+	for {
+		v, err = am.nextVlan()
+		if err != nil {
+			return 0, err
+		}
+		if !am.isReserved(v) {
+			return v, nil
+		}
+	}
+}
+
+func (am *adapterManager) nextVlan() (v uint16, err error) {
 	if len(am.vlanRecover) != 0 {
 		return <-am.vlanRecover, nil
 	}
+	am.lock.Lock()
 	if am.vlanID < 65535 {
 		am.vlanID++
-		return am.vlanID, nil
+		v = am.vlanID
+		am.lock.Unlock()
+		return v, nil
 	}
+	am.lock.Unlock()
 	select {
 	case v := <-am.vlanRecover:
 		return v, nil
 	case <-time.After(10 * time.Second):
 		return 0, fmt.Errorf("no available vlan id")
 	}
+}
+
+func (am *adapterManager) isReserved(v uint16) bool {
+	am.lock.RLock()
+	defer am.lock.RUnlock()
+	_, ok := am.reserved[v]
+	return ok
+}
 
+// ReserveVlan 保留vlan地址，保留的地址不会被自动分配，只能通过AddReservedPeer使用
+func (am *adapterManager) ReserveVlan(v uint16) bool {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	if v <= 1 {
+		return false
+	}
+	if _, ok := am.reserved[v]; ok {
+		return false
+	}
+	am.reserved[v] = struct{}{}
+	// 保留的地址超过当前分配进度时直接推进，避免后续重复分配
+	if v > am.vlanID {
+		for i := am.vlanID + 1; i < v; i++ {
+			am.vlanRecover <- i
+		}
+		am.vlanID = v
+	}
+	return true
+}
+
+// ReleaseVlan 释放未被使用的保留地址
+func (am *adapterManager) ReleaseVlan(v uint16) {
+	am.lock.Lock()
+	defer am.lock.Unlock()
+	if _, ok := am.reserved[v]; !ok {
+		return
+	}
+	delete(am.reserved, v)
+	am.vlanRecover <- v
 }
 
 func (am *adapterManager) Close() error {
@@ -91,17 +146,34 @@ func (am *adapterManager) Close() error {
 
 // 添加局域网成员 uid: ws连接唯一标识，hook: peer对端地址改变后的回调函数，传入uid和新地址
 func (am *adapterManager) AddPeer(uid string, publicKey string, hook func(uid string, ip string, port int)) (vlan uint16, err error) {
+	vlan_ip, err := am.vlan()
+	if err != nil {
+		return 0, err
+	}
+	return am.addPeer(uid, publicKey, vlan_ip, hook)
+}
 
+// AddReservedPeer 使用已保留的vlan地址添加局域网成员
+func (am *adapterManager) AddReservedPeer(uid string, publicKey string, vlan uint16, hook func(uid string, ip string, port int)) (uint16, error) {
+	am.lock.Lock()
+	if _, ok := am.reserved[vlan]; !ok {
+		am.lock.Unlock()
+		return 0, fmt.Errorf("vlan %d is not reserved", vlan)
+	}
+	delete(am.reserved, vlan)
+	am.lock.Unlock()
+	return am.addPeer(uid, publicKey, vlan, hook)
+}
+
+func (am *adapterManager) addPeer(uid string, publicKey string, vlan_ip uint16, hook func(uid string, ip string, port int)) (vlan uint16, err error) {
 	pubKey, err := base64.StdEncoding.DecodeString(publicKey)
 	if err != nil || len(pubKey) != device.NoisePublicKeySize {
-		return 0, err
+		// 回收vlan地址
+		am.vlanRecover <- vlan_ip
+		return 0, fmt.Errorf("invalid public key")
 	}
 	var pubByte device.NoisePublicKey
 	copy(pubByte[:], pubKey)
-	vlan_ip, err := am.vlan()
-	if err != nil {
-		return 0, err
-	}
 	vlan_ip_string := fmt.Sprintf("%d.%d.%d.%d/32", config.Conf.Server.Vlan[0], config.Conf.Server.Vlan[1], vlan_ip>>8, vlan_ip&0xff)
 	wgPeer, err := server.Device.NewPeerFix(uid, pubByte, vlan_ip_string, hook)
 	if err != nil {
@@ -166,6 +238,7 @@ func init() {
 		},
 		vlanID:      1,
 		vlanRecover: make(chan uint16, 1<<16),
+		reserved:    make(map[uint16]struct{}),
 	}
 	loguru.SimpleLog(loguru.Debug, "WG", fmt.Sprintf("generate wg pub key %s", WireguardManager.GetPublicKey()))
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"ginWeb/config"
 	"ginWeb/utils/loguru"
+	"ginWeb/utils/tools"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
@@ -12,6 +13,7 @@ import (
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 	"log"
+	"testing"
 	"time"
 )
 
@@ -40,9 +42,16 @@ func (l *dbLogger) Trace(ctx context.Context, begin time.Time, fc func() (string
 		l.Logger.Errorf("[GORM] | Error: %v", err)
 	}
 	sql, _ := fc()
+	if meta, ok := tools.MetaFrom(ctx); ok {
+		l.Logger.Infof("[GORM] | trace: %s | SQL: %s", meta.TraceId, sql)
+		return
+	}
 	l.Logger.Infof("[GORM] | SQL: %s", sql)
 }
 
+// redis操作默认超时时间
+const redisTimeout = 100 * time.Millisecond
+
 // Db 数据库连接对象
 var Db *gorm.DB
 
@@ -50,6 +59,10 @@ var Db *gorm.DB
 var Rdb *redis.Client
 
 func init() {
+	// 单元测试不连接数据库，需要数据库的逻辑不在单元测试范围内
+	if testing.Testing() {
+		return
+	}
 	logu := &dbLogger{
 		Logger: loguru.DbLogger,
 	}
@@ -88,3 +101,13 @@ func init() {
 	}
 	Rdb = rdb
 }
+
+// WithContext 绑定上下文的数据库会话，上下文取消时中断查询
+func WithContext(ctx context.Context) *gorm.DB {
+	return Db.WithContext(ctx)
+}
+
+// RedisContext 在ctx基础上添加redis操作的默认超时
+func RedisContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, redisTimeout)
+}
@@ -0,0 +1,29 @@
+package tools
+
+import "context"
+
+// RequestMeta 请求上下文携带的元数据
+type RequestMeta struct {
+	RequestId string // 请求id
+	TraceId   string // 链路追踪id，客户端未提供时自动生成
+	Method    string // 请求方法
+	UserId    int64
+	UserUuid  string
+	UserName  string
+}
+
+type metaKey struct{}
+
+// WithMeta 在上下文中附加请求元数据
+func WithMeta(ctx context.Context, meta *RequestMeta) context.Context {
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+// MetaFrom 获取上下文中的请求元数据
+func MetaFrom(ctx context.Context) (*RequestMeta, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	meta, ok := ctx.Value(metaKey{}).(*RequestMeta)
+	return meta, ok
+}
@@ -64,7 +64,12 @@ func ShouldBindJson(data []byte, target interface{}) error {
 	if err != nil {
 		return err
 	}
-	err = jsonFormatter.Struct(target)
+	return Validate(target)
+}
+
+// Validate 按validate标签校验结构体
+func Validate(target interface{}) error {
+	err := jsonFormatter.Struct(target)
 	if err != nil {
 		var validationErrors validator.ValidationErrors
 		switch {
@@ -93,6 +98,13 @@ func GenerateRandomString(length int) string {
 	return string(result)
 }
 
+// RegisterValidation 注册自定义校验标签
+func RegisterValidation(tag string, fn validator.Func) {
+	if err := jsonFormatter.RegisterValidation(tag, fn); err != nil {
+		panic(err)
+	}
+}
+
 func init() {
 	jsonFormatter = validator.New()
 }
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJsonType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Schema 按json、validate和binding标签生成类型的JSON Schema
+func Schema(t reflect.Type) map[string]interface{} {
+	return schemaOf(t, map[reflect.Type]bool{})
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := map[string]interface{}{}
+	switch {
+	case t == timeType:
+		s["type"] = "string"
+		s["format"] = "date-time"
+	case t == rawJsonType || t.Kind() == reflect.Interface:
+		// 任意类型
+	case t.Kind() == reflect.String:
+		s["type"] = "string"
+	case t.Kind() == reflect.Bool:
+		s["type"] = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s["type"] = "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s["type"] = "number"
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8:
+		s["type"] = "string"
+		s["contentEncoding"] = "base64"
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s["type"] = "array"
+		s["items"] = schemaOf(t.Elem(), visiting)
+		if t.Kind() == reflect.Array {
+			s["minItems"] = t.Len()
+			s["maxItems"] = t.Len()
+		}
+	case t.Kind() == reflect.Map:
+		s["type"] = "object"
+		s["additionalProperties"] = schemaOf(t.Elem(), visiting)
+	case t.Kind() == reflect.Struct:
+		// 递归类型不再展开
+		if visiting[t] {
+			s["type"] = "object"
+			break
+		}
+		visiting[t] = true
+		properties, required := structProperties(t, visiting)
+		delete(visiting, t)
+		s["type"] = "object"
+		s["properties"] = properties
+		if len(required) > 0 {
+			s["required"] = required
+		}
+	}
+	if nullable && s["type"] != nil {
+		s["type"] = []interface{}{s["type"], "null"}
+	}
+	return s
+}
+
+// 结构体字段的Schema和必填字段，匿名嵌入的结构体字段展开
+func structProperties(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, []string) {
+	properties := map[string]interface{}{}
+	required := make([]string, 0)
+	for _, f := range SchemaFields(t) {
+		s := schemaOf(f.Type, visiting)
+		if f.Constrain(s) {
+			required = append(required, f.Name)
+		}
+		properties[f.Name] = s
+	}
+	return properties, required
+}
+
+// SchemaField 参与json编码的结构体字段
+type SchemaField struct {
+	reflect.StructField
+	Name string // json名称
+}
+
+// Constrain 将字段的validate和binding标签转为Schema约束，返回字段是否必填
+func (f SchemaField) Constrain(s map[string]interface{}) bool {
+	validate := applyRules(s, f.Tag.Get("validate"))
+	binding := applyRules(s, f.Tag.Get("binding"))
+	return validate || binding
+}
+
+// SchemaFields 按定义顺序返回参与json编码的字段，匿名嵌入的结构体字段展开
+func SchemaFields(t reflect.Type) []SchemaField {
+	list := make([]SchemaField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && (tag == "" || strings.HasPrefix(tag, ",")) {
+			list = append(list, SchemaFields(f.Type)...)
+			continue
+		}
+		if name, ok := JsonName(f); ok {
+			list = append(list, SchemaField{StructField: f, Name: name})
+		}
+	}
+	return list
+}
+
+// JsonName 字段的json名称，不参与编码的字段返回false
+func JsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
+
+// 将校验规则转为Schema约束，返回是否包含required
+func applyRules(s map[string]interface{}, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, value, _ := strings.Cut(rule, "=")
+		n, err := strconv.ParseFloat(value, 64)
+		switch {
+		case name == "required":
+			required = true
+		case name == "oneof":
+			s["enum"] = strings.Fields(value)
+		case err != nil:
+		case name == "min" || name == "gte":
+			setBound(s, "min", n)
+		case name == "max" || name == "lte":
+			setBound(s, "max", n)
+		case name == "len":
+			setBound(s, "min", n)
+			setBound(s, "max", n)
+		}
+	}
+	return required
+}
+
+// 按类型设置长度、数量或数值范围
+func setBound(s map[string]interface{}, bound string, n float64) {
+	typ := s["type"]
+	if types, ok := typ.([]interface{}); ok {
+		typ = types[0]
+	}
+	switch typ {
+	case "string":
+		s[bound+"Length"] = int(n)
+	case "array":
+		s[bound+"Items"] = int(n)
+	case "object":
+		s[bound+"Properties"] = int(n)
+	case "integer", "number":
+		s[bound+"imum"] = n
+	}
+}